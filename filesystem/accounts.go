@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// accountDatabase resolves user and group names from passwd(5) and group(5) formatted files,
+// falling back to the host name service (NSS) for the database files left unset. Files are read
+// on each lookup since they can themselves be managed by the provider (e.g. when building a root
+// filesystem). IDs missing from the database files are named numerically, as the IDs of the host
+// accounts usually are in root filesystem images and chroots.
+type accountDatabase struct {
+	passwdFile string
+	groupFile  string
+}
+
+func (a accountDatabase) lookupUser(name string) (int, error) {
+	if a.passwdFile == "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(u.Uid)
+	}
+
+	entry, err := lookupDatabaseEntry(a.passwdFile, 0, name)
+	if err != nil {
+		return 0, err
+	} else if entry == nil {
+		if uid, err := strconv.Atoi(name); err == nil && uid >= 0 {
+			return uid, nil
+		}
+		return 0, user.UnknownUserError(name)
+	}
+	return strconv.Atoi(entry[2])
+}
+
+func (a accountDatabase) lookupUserID(uid int) (string, error) {
+	if a.passwdFile == "" {
+		u, err := user.LookupId(strconv.Itoa(uid))
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	}
+
+	entry, err := lookupDatabaseEntry(a.passwdFile, 2, strconv.Itoa(uid))
+	if err != nil {
+		return "", err
+	} else if entry == nil {
+		return strconv.Itoa(uid), nil
+	}
+	return entry[0], nil
+}
+
+func (a accountDatabase) lookupGroup(name string) (int, error) {
+	if a.groupFile == "" {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(g.Gid)
+	}
+
+	entry, err := lookupDatabaseEntry(a.groupFile, 0, name)
+	if err != nil {
+		return 0, err
+	} else if entry == nil {
+		if gid, err := strconv.Atoi(name); err == nil && gid >= 0 {
+			return gid, nil
+		}
+		return 0, user.UnknownGroupError(name)
+	}
+	return strconv.Atoi(entry[2])
+}
+
+func (a accountDatabase) lookupGroupID(gid int) (string, error) {
+	if a.groupFile == "" {
+		g, err := user.LookupGroupId(strconv.Itoa(gid))
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	}
+
+	entry, err := lookupDatabaseEntry(a.groupFile, 2, strconv.Itoa(gid))
+	if err != nil {
+		return "", err
+	} else if entry == nil {
+		return strconv.Itoa(gid), nil
+	}
+	return entry[0], nil
+}
+
+func (a accountDatabase) currentUsername() (string, error) {
+	name, err := a.lookupUserID(os.Getuid())
+	if err != nil {
+		return "", fmt.Errorf("unable to lookup current user name: %s", err)
+	}
+	return name, nil
+}
+
+func (a accountDatabase) currentUserGroupname() (string, error) {
+	name, err := a.lookupGroupID(os.Getgid())
+	if err != nil {
+		return "", fmt.Errorf("unable to lookup current user group name: %s", err)
+	}
+	return name, nil
+}
+
+// lookupDatabaseEntry returns the fields of the first entry of a colon-separated database file
+// (such as /etc/passwd or /etc/group) having the given value as field, or nil if not found
+func lookupDatabaseEntry(path string, field int, value string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry := strings.Split(line, ":")
+		if len(entry) < 3 {
+			continue
+		}
+
+		if entry[field] == value {
+			return entry, nil
+		}
+	}
+
+	return nil, scanner.Err()
+}
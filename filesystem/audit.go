@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// auditJournal is an append-only JSON lines journal of the filesystem changes performed by the
+// provider
+type auditJournal struct {
+	file *os.File
+	sync.Mutex
+}
+
+type auditEntry struct {
+	Timestamp string          `json:"timestamp"`
+	Operation string          `json:"operation"`
+	Path      string          `json:"path"`
+	Before    *auditFileState `json:"before"`
+	After     *auditFileState `json:"after"`
+}
+
+// auditFileState describes a file at a point in time (a nil state meaning the file doesn't exist)
+type auditFileState struct {
+	Mode   string `json:"mode"`
+	User   string `json:"user"`
+	Group  string `json:"group"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+func newAuditJournal(path string) (*auditJournal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit journal: %s", err)
+	}
+
+	return &auditJournal{file: file}, nil
+}
+
+func (j *auditJournal) write(entry auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write audit journal entry: %s", err)
+	}
+
+	return j.file.Sync()
+}
+
+// auditState returns the current state of the file at path for the audit journal, or nil if
+// auditing is disabled or if the file doesn't exist
+func (p filesystemProvider) auditState(path string) *auditFileState {
+	if p.audit == nil {
+		return nil
+	}
+
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	state := &auditFileState{
+		Mode:  fmt.Sprintf("%#o", fileInfo.Mode()),
+		User:  strconv.Itoa(int(stat.Uid)),
+		Group: strconv.Itoa(int(stat.Gid)),
+	}
+
+	if username, err := p.accounts.lookupUserID(int(stat.Uid)); err == nil {
+		state.User = username
+	}
+
+	if groupname, err := p.accounts.lookupGroupID(int(stat.Gid)); err == nil {
+		state.Group = groupname
+	}
+
+	if fileInfo.Mode().IsRegular() {
+		if sha, err := p.hashFile(path); err == nil {
+			state.SHA256 = sha
+		}
+	}
+
+	return state
+}
+
+// auditRecord records a filesystem change in the audit journal, if enabled
+func (p filesystemProvider) auditRecord(operation, path string, before *auditFileState) error {
+	if p.audit == nil {
+		return nil
+	}
+
+	return p.audit.write(auditEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Operation: operation,
+		Path:      path,
+		Before:    before,
+		After:     p.auditState(path),
+	})
+}
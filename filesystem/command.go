@@ -0,0 +1,173 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/facette/logger"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// defaultCommandTimeout is the default timeout of the commands run on resource changes
+const defaultCommandTimeout = "1m"
+
+// withCommandHooks adds to a resource schema the attributes of the commands run when the resource
+// is created, changed or destroyed
+func withCommandHooks(schemas map[string]*schema.Schema) map[string]*schema.Schema {
+	for hook, event := range map[string]string{"create": "created", "change": "changed", "destroy": "destroyed"} {
+		schemas["on_"+hook+"_command"] = &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "Command run with /bin/sh after the resource is " + event,
+			Optional:    true,
+			ForceNew:    false,
+		}
+	}
+
+	schemas["command_timeout"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "Timeout of the commands run on resource changes (e.g. 30s)",
+		Optional:    true,
+		Default:     defaultCommandTimeout,
+		ForceNew:    false,
+		ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+			if timeout, err := time.ParseDuration(i.(string)); err != nil || timeout <= 0 {
+				errors = append(errors, fmt.Errorf("%q: invalid value", k))
+			}
+			return
+		},
+	}
+
+	schemas["command_failure_policy"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "Outcome of the failure of commands run on resource changes (fail or warn)",
+		Optional:    true,
+		Default:     "fail",
+		ForceNew:    false,
+		ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+			switch i.(string) {
+			case "fail", "warn":
+			default:
+				errors = append(errors, fmt.Errorf("%q: invalid value", k))
+			}
+			return
+		},
+	}
+
+	return schemas
+}
+
+// runCommand runs a command line with /bin/sh, with the given variables added to the provider
+// environment, the command output being included in the returned error if it fails
+func (p filesystemProvider) runCommand(command string, env []string) error {
+	if err := interrupted(p.ctx); err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+
+	// Commands run in their own process group, killed as a whole when the operation is
+	// interrupted, as their children would otherwise keep running and hold their output open
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-p.ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		err = <-done
+	}
+	if err != nil {
+		if ctxErr := interrupted(p.ctx); ctxErr != nil {
+			err = ctxErr
+		}
+		return fmt.Errorf("command %q failed: %s\n%s", command, err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+// shellQuote quotes a string to be used as a single word in a /bin/sh command line
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// contentValidator returns the function validating the content written to a temporary file with
+// the validate_command attribute, if set
+func (p filesystemProvider) contentValidator(command string) func(string) error {
+	if command == "" {
+		return nil
+	}
+
+	return func(path string) error {
+		if err := p.runCommand(strings.Replace(command, "%s", shellQuote(path), -1), nil); err != nil {
+			return fmt.Errorf("content validation failed, file left unchanged: %s", err)
+		}
+		return nil
+	}
+}
+
+func validateValidateCommand(i interface{}, k string) (ws []string, errors []error) {
+	if command := i.(string); command != "" && !strings.Contains(command, "%s") {
+		errors = append(errors, fmt.Errorf("%q: must contain %%s, replaced by the path of the file to validate", k))
+	}
+	return
+}
+
+// hookHash returns the SHA256 digest of the file at path for the command of the given hook, if set
+func (p filesystemProvider) hookHash(d *schema.ResourceData, hook, path string) (string, error) {
+	if d.Get("on_"+hook+"_command").(string) == "" {
+		return "", nil
+	}
+	return p.hashFile(path)
+}
+
+// runHook runs the command of the given hook, if set, with the path of the resource and the
+// SHA256 digests of the file content before and after the change in its environment. Depending on
+// the failure policy of the resource, failures are either errors or logged as warnings.
+func (p filesystemProvider) runHook(d *schema.ResourceData, hook, path, oldHash, newHash string, log *logger.Logger) error {
+	command := d.Get("on_" + hook + "_command").(string)
+	if command == "" {
+		return nil
+	}
+
+	timeout, _ := time.ParseDuration(d.Get("command_timeout").(string))
+
+	var cancel context.CancelFunc
+	p.ctx, cancel = context.WithTimeout(p.ctx, timeout)
+	defer cancel()
+
+	err := p.runCommand(command, []string{
+		"FILESYSTEM_EVENT=" + hook,
+		"FILESYSTEM_PATH=" + path,
+		"FILESYSTEM_OLD_SHA256=" + oldHash,
+		"FILESYSTEM_NEW_SHA256=" + newHash,
+	})
+	if err != nil {
+		if d.Get("command_failure_policy").(string) == "warn" {
+			log.Warning("on_%s_command failed: %s", hook, err)
+			return nil
+		}
+		return fmt.Errorf("on_%s_command failed: %s", hook, err)
+	}
+
+	log.Info("ran on_%s_command", hook)
+
+	return nil
+}
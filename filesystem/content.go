@@ -0,0 +1,159 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/facette/logger"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// maxStateContentSize is the size above which file content is stored in state as a digest, even
+// if store_content_in_state is set
+const maxStateContentSize = 64 * 1024
+
+// maxLineDiffSize is the product of the old and new line counts above which content line diffs
+// aren't computed
+const maxLineDiffSize = 1000000
+
+// stateContent returns the value of the content attribute to store in state: the content itself if
+// storing content in state is requested and the content is small enough text, its digest otherwise
+func stateContent(content string, store bool) string {
+	if store && len(content) <= maxStateContentSize && utf8.ValidString(content) && !strings.ContainsRune(content, 0) {
+		return content
+	}
+	return contentDigest(content)
+}
+
+// readStateContent returns the value of the content attribute to store in state for the file at
+// path, only reading the whole file content if it may be stored in state
+func (p filesystemProvider) readStateContent(path string, store bool) (string, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !store || fileInfo.Size() > maxStateContentSize {
+		sha, err := p.hashFile(path)
+		if err != nil {
+			return "", err
+		}
+		return contentDigestPrefix + sha, nil
+	}
+
+	file, err := openNoatime(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+
+	return stateContent(string(content), true), nil
+}
+
+// isContentDigest reports whether a content attribute value stored in state is a digest
+func isContentDigest(value string) bool {
+	return strings.HasPrefix(value, contentDigestPrefix) && sha256HexRegexp.MatchString(strings.TrimPrefix(value, contentDigestPrefix))
+}
+
+// diffContent adjusts the content attribute diff of a filesystem_file resource, as the content
+// attribute holds either the content or its digest depending on store_content_in_state: the plan
+// shows the actual content when stored in state, along with its line changes in the content_diff
+// attribute (also logged)
+func diffContent(s *terraform.InstanceState, diff *terraform.InstanceDiff, log *logger.Logger) {
+	contentDiff, ok := diff.Attributes["content"]
+	if !ok || contentDiff.NewComputed {
+		return
+	}
+
+	content, ok := contentDiff.NewExtra.(string)
+	if !ok {
+		return
+	}
+
+	// Content unchanged, but stored differently in state (unless the resource is replaced, the diff
+	// then describing the new resource)
+	if !diff.RequiresNew() && (contentDiff.Old == content || contentDiff.Old == contentDigest(content)) {
+		delete(diff.Attributes, "content")
+		return
+	}
+
+	store := s != nil && s.Attributes["store_content_in_state"] == "true"
+	if storeDiff, ok := diff.Attributes["store_content_in_state"]; ok {
+		store = storeDiff.New == "true"
+	}
+
+	contentDiff.New = stateContent(content, store)
+
+	if !isContentDigest(contentDiff.Old) && !isContentDigest(contentDiff.New) {
+		if lines := lineDiff(contentDiff.Old, contentDiff.New); lines != "" {
+			var old string
+			if s != nil {
+				old = s.Attributes["content_diff"]
+			}
+			diff.Attributes["content_diff"] = &terraform.ResourceAttrDiff{Old: old, New: lines}
+
+			log.Info("content changes:\n%s", lines)
+		}
+	}
+}
+
+// lineDiff returns the line differences between two texts (removed lines being prefixed with "-",
+// added ones with "+", and unchanged ones with " "), or an empty string if the texts are too large
+func lineDiff(old, new string) string {
+	a := strings.SplitAfter(old, "\n")
+	b := strings.SplitAfter(new, "\n")
+
+	if len(a)*len(b) > maxLineDiffSize {
+		return ""
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff strings.Builder
+	line := func(prefix, s string) {
+		if s != "" {
+			fmt.Fprintf(&diff, "%s%s\n", prefix, strings.TrimSuffix(s, "\n"))
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			line(" ", a[i])
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			line("-", a[i])
+			i++
+		default:
+			line("+", b[j])
+			j++
+		}
+	}
+
+	return diff.String()
+}
@@ -0,0 +1,33 @@
+package filesystem
+
+import "testing"
+
+func TestLineDiff(t *testing.T) {
+	cases := map[string]struct {
+		Old      string
+		New      string
+		Expected string
+	}{
+		"changed": {
+			Old:      "a\nb\nc\n",
+			New:      "a\nB\nc\n",
+			Expected: " a\n-b\n+B\n c\n",
+		},
+		"added": {
+			Old:      "a\n",
+			New:      "a\nb",
+			Expected: " a\n+b\n",
+		},
+		"removed": {
+			Old:      "a\nb\n",
+			New:      "",
+			Expected: "-a\n-b\n",
+		},
+	}
+
+	for name, tc := range cases {
+		if diff := lineDiff(tc.Old, tc.New); diff != tc.Expected {
+			t.Fatalf("%s: line diff (%q) different from expected diff (%q)", name, diff, tc.Expected)
+		}
+	}
+}
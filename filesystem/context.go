@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// defaultTimeout is the default timeout of the resources create, update and delete operations
+const defaultTimeout = 10 * time.Minute
+
+// copyChunkSize is the size of the chunks copied between two cancellation checks
+const copyChunkSize = 1 << 20
+
+func resourceTimeouts() *schema.ResourceTimeout {
+	return &schema.ResourceTimeout{
+		Create: schema.DefaultTimeout(defaultTimeout),
+		Update: schema.DefaultTimeout(defaultTimeout),
+		Delete: schema.DefaultTimeout(defaultTimeout),
+	}
+}
+
+// withTimeout returns a copy of the provider whose filesystem operations are interrupted when the
+// resource operation timeout expires, along with the function releasing the timeout resources
+func (p filesystemProvider) withTimeout(d *schema.ResourceData, key string) (filesystemProvider, context.CancelFunc) {
+	var cancel context.CancelFunc
+	p.ctx, cancel = context.WithTimeout(p.ctx, d.Timeout(key))
+	return p, cancel
+}
+
+// interrupted returns an error if the context has been cancelled, either because Terraform is
+// stopping or because the operation timed out
+func interrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("operation interrupted: %s", err)
+	}
+	return nil
+}
+
+// copyContext copies src to dst by chunks, checking for cancellation before each chunk
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader) error {
+	for {
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+
+		if _, err := io.CopyN(dst, src, copyChunkSize); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// hashFile returns the SHA256 digest of the content of the file at path, as computed by hash()
+func (p filesystemProvider) hashFile(path string) (string, error) {
+	file, err := openNoatime(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	if err := copyContext(p.ctx, sha, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sha.Sum(nil)), nil
+}
+
+// readFile returns the content of the file at path
+func (p filesystemProvider) readFile(path string) (string, error) {
+	file, err := openNoatime(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var content bytes.Buffer
+	if err := copyContext(p.ctx, &content, file); err != nil {
+		return "", err
+	}
+
+	return content.String(), nil
+}
@@ -0,0 +1,222 @@
+package filesystem
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The following functions write and parse environment files of the following dialects:
+//   - shell: POSIX shell variable assignments, values being single-quoted
+//   - systemd: systemd EnvironmentFile, values being double-quoted with backslash escapes
+//   - docker-compose: docker compose .env files, values being single-quoted, or double-quoted with
+//     escapes and $$ for literal dollar signs when they hold single quotes
+// Values only made of safe characters are written unquoted, in every dialect.
+
+// envDialects are the supported dialects of environment files
+var envDialects = []string{"shell", "systemd", "docker-compose"}
+
+var (
+	// envKeyRegexp matches valid variable names
+	envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// envSafeValueRegexp matches the values which don't need quoting in any dialect
+	envSafeValueRegexp = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+var (
+	systemdEnvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+	composeEnvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `$$`)
+)
+
+// formatEnvFile returns the content of an environment file of the given dialect, defining the
+// given variables in key order
+func formatEnvFile(variables map[string]interface{}, dialect string) string {
+	var content strings.Builder
+	for _, key := range sortedKeys(variables) {
+		content.WriteString(key + "=" + quoteEnvValue(variables[key].(string), dialect) + "\n")
+	}
+	return content.String()
+}
+
+// quoteEnvValue quotes a value for the given dialect, if needed
+func quoteEnvValue(value, dialect string) string {
+	if envSafeValueRegexp.MatchString(value) {
+		return value
+	}
+
+	switch dialect {
+	case "systemd":
+		return `"` + systemdEnvEscaper.Replace(value) + `"`
+	case "docker-compose":
+		if !strings.Contains(value, "'") {
+			return "'" + value + "'"
+		}
+		return `"` + composeEnvEscaper.Replace(value) + `"`
+	default:
+		return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+	}
+}
+
+// parseEnvFile returns the variables defined by an environment file of the given dialect. Comments,
+// export prefixes and invalid lines are skipped, the last definition of a variable prevailing.
+func parseEnvFile(content, dialect string) map[string]string {
+	variables := map[string]string{}
+
+	for i := 0; i < len(content); {
+		lineEnd := strings.IndexByte(content[i:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += i
+		}
+
+		line := strings.TrimLeft(content[i:lineEnd], " \t")
+		if dialect != "systemd" {
+			line = strings.TrimPrefix(line, "export ")
+		}
+
+		equal := strings.IndexByte(line, '=')
+		if line == "" || line[0] == '#' || dialect == "systemd" && line[0] == ';' || equal < 0 {
+			i = lineEnd + 1
+			continue
+		}
+
+		key := line[:equal]
+		if dialect != "shell" {
+			key = strings.TrimRight(key, " \t")
+		}
+		if !envKeyRegexp.MatchString(key) {
+			i = lineEnd + 1
+			continue
+		}
+
+		// Values may span several lines
+		start := lineEnd - len(line) + equal + 1
+		var value string
+		if dialect == "docker-compose" {
+			value, i = parseComposeEnvValue(content, start)
+		} else {
+			value, i = parseShellEnvValue(content, start, dialect == "shell")
+		}
+		variables[key] = value
+	}
+
+	return variables
+}
+
+// parseShellEnvValue parses the shell or systemd value starting at offset i, and returns it along
+// with the offset of the following line. Shell values end at the first unquoted whitespace, while
+// systemd values end at the end of the line, trailing whitespace excluded.
+func parseShellEnvValue(content string, i int, shell bool) (string, int) {
+	var value strings.Builder
+	trimmed := 0
+
+	if !shell {
+		for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+			i++
+		}
+	}
+
+	for ; i < len(content) && content[i] != '\n'; i++ {
+		switch c := content[i]; {
+		case c == '\\' && i+1 < len(content):
+			i++
+			if content[i] != '\n' {
+				value.WriteByte(content[i])
+			}
+			trimmed = value.Len()
+
+		case c == '\'':
+			end := strings.IndexByte(content[i+1:], '\'')
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			value.WriteString(content[i+1 : i+1+end])
+			i += end + 1
+			trimmed = value.Len()
+
+		case c == '"':
+			for i++; i < len(content) && content[i] != '"'; i++ {
+				if content[i] == '\\' && i+1 < len(content) && strings.IndexByte("$`\"\\\n", content[i+1]) >= 0 {
+					if i++; content[i] == '\n' {
+						continue
+					}
+				}
+				value.WriteByte(content[i])
+			}
+			trimmed = value.Len()
+
+		case (c == ' ' || c == '\t') && shell:
+			// The rest of the line is a comment or a command
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			return value.String(), i + 1
+
+		default:
+			value.WriteByte(c)
+			if c != ' ' && c != '\t' && c != '\r' {
+				trimmed = value.Len()
+			}
+		}
+	}
+
+	return value.String()[:trimmed], i + 1
+}
+
+// composeEnvEscapeRegexp matches the escape sequences of double-quoted docker-compose values
+var composeEnvEscapeRegexp = regexp.MustCompile(`\\.|\$\$`)
+
+// parseComposeEnvValue parses the docker-compose value starting at offset i, and returns it along
+// with the offset of the following line
+func parseComposeEnvValue(content string, i int) (string, int) {
+	for i < len(content) && (content[i] == ' ' || content[i] == '\t') {
+		i++
+	}
+
+	if i < len(content) && (content[i] == '\'' || content[i] == '"') {
+		quote := content[i]
+
+		end := i + 1
+		for end < len(content) && content[end] != quote {
+			if quote == '"' && content[end] == '\\' {
+				end++
+			}
+			end++
+		}
+
+		if end < len(content) {
+			next := strings.IndexByte(content[end:], '\n')
+			if next < 0 {
+				next = len(content) - end
+			}
+
+			value := content[i+1 : end]
+			if quote == '"' {
+				value = composeEnvEscapeRegexp.ReplaceAllStringFunc(value, func(escape string) string {
+					switch escape {
+					case `\n`:
+						return "\n"
+					case `\r`:
+						return "\r"
+					case `$$`:
+						return "$"
+					}
+					return escape[1:]
+				})
+			}
+			return value, end + next + 1
+		}
+	}
+
+	end := strings.IndexByte(content[i:], '\n')
+	if end < 0 {
+		end = len(content) - i
+	}
+
+	value := content[i : i+end]
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = value[:comment]
+	}
+	return strings.TrimRight(value, " \t\r"), i + end + 1
+}
@@ -0,0 +1,69 @@
+package filesystem
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatEnvFile(t *testing.T) {
+	variables := map[string]interface{}{
+		"PLAIN":  "/usr/bin:/bin",
+		"EMPTY":  "",
+		"SPACES": " a  b ",
+		"QUOTES": `it's "quoted"`,
+		"SHELL":  "$HOME `id` \\n",
+		"LINES":  "a\nb",
+	}
+
+	cases := map[string]string{
+		"shell":          "EMPTY=\nLINES='a\nb'\nPLAIN=/usr/bin:/bin\nQUOTES='it'\\''s \"quoted\"'\nSHELL='$HOME `id` \\n'\nSPACES=' a  b '\n",
+		"systemd":        "EMPTY=\nLINES=\"a\nb\"\nPLAIN=/usr/bin:/bin\nQUOTES=\"it's \\\"quoted\\\"\"\nSHELL=\"\\$HOME \\`id\\` \\\\n\"\nSPACES=\" a  b \"\n",
+		"docker-compose": "EMPTY=\nLINES='a\nb'\nPLAIN=/usr/bin:/bin\nQUOTES=\"it's \\\"quoted\\\"\"\nSHELL='$HOME `id` \\n'\nSPACES=' a  b '\n",
+	}
+
+	for dialect, expected := range cases {
+		content := formatEnvFile(variables, dialect)
+		if content != expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", dialect, content, expected)
+		}
+
+		parsed := map[string]interface{}{}
+		for key, value := range parseEnvFile(content, dialect) {
+			parsed[key] = value
+		}
+
+		if !reflect.DeepEqual(parsed, variables) {
+			t.Fatalf("%s: parsed variables (%q) different from expected variables (%q)", dialect, parsed, variables)
+		}
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	cases := map[string]struct {
+		Dialect  string
+		Content  string
+		Expected map[string]string
+	}{
+		"shell": {
+			Dialect:  "shell",
+			Content:  "# defaults\nexport A=1\nB=\"x $y\"'z'\\ w # comment\nC=a b\ninvalid line\nD-E=1\nA=2\n",
+			Expected: map[string]string{"A": "2", "B": "x $yz w", "C": "a"},
+		},
+		"systemd": {
+			Dialect:  "systemd",
+			Content:  "; defaults\n# comment\nA = 1\nB=  a b  \nC=\"x\\ny\\\"\"\nD=a\\\nb\n",
+			Expected: map[string]string{"A": "1", "B": "a b", "C": "x\\ny\"", "D": "ab"},
+		},
+		"docker-compose": {
+			Dialect:  "docker-compose",
+			Content:  "# defaults\nA = 1 # comment\nB=\"a\\nb $$c\" # comment\nC='${D}'\nexport E=e\n",
+			Expected: map[string]string{"A": "1", "B": "a\nb $c", "C": "${D}", "E": "e"},
+		},
+	}
+
+	for name, tc := range cases {
+		if variables := parseEnvFile(tc.Content, tc.Dialect); !reflect.DeepEqual(variables, tc.Expected) {
+			t.Fatalf("%s: variables (%q) different from expected variables (%q)", name, variables, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-ini/ini"
+	"github.com/hashicorp/terraform/terraform"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// validateContentFormat checks that content is a well-formed document of the given format
+func validateContentFormat(format, content string) error {
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(strings.NewReader(content))
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			if syntaxErr, ok := err.(*json.SyntaxError); ok {
+				return fmt.Errorf("invalid JSON: line %d: %s", strings.Count(content[:syntaxErr.Offset], "\n")+1, err)
+			}
+			return fmt.Errorf("invalid JSON: %s", err)
+		}
+
+		if _, err := decoder.Token(); err != io.EOF {
+			return fmt.Errorf("invalid JSON: unexpected data after top-level value")
+		}
+
+	case "yaml":
+		for decoder := yaml.NewDecoder(strings.NewReader(content)); ; {
+			var node yaml.Node
+			if err := decoder.Decode(&node); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return fmt.Errorf("invalid YAML: %s", err)
+			}
+		}
+
+	case "toml":
+		var value map[string]interface{}
+		if _, err := toml.Decode(content, &value); err != nil {
+			return fmt.Errorf("invalid TOML: %s", err)
+		}
+
+	case "ini":
+		if _, err := ini.Load([]byte(content)); err != nil {
+			return fmt.Errorf("invalid INI: %s", err)
+		}
+
+	case "xml":
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		depth, roots := 0, 0
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return fmt.Errorf("invalid XML: %s", err)
+			}
+
+			switch token.(type) {
+			case xml.StartElement:
+				if depth == 0 {
+					roots++
+				}
+				depth++
+			case xml.EndElement:
+				depth--
+			}
+		}
+
+		if roots != 1 {
+			return fmt.Errorf("invalid XML: %d root elements instead of 1", roots)
+		}
+	}
+
+	return nil
+}
+
+// checkContentFormat checks that the content of a content attribute is a well-formed document of
+// the given format, without revealing sensitive content in error messages
+func checkContentFormat(attribute, format, content string) error {
+	if err := validateContentFormat(format, content); err != nil {
+		if attribute == "sensitive_content" {
+			return fmt.Errorf("%s: invalid %s document", attribute, strings.ToUpper(format))
+		}
+		return fmt.Errorf("%s: %s", attribute, err)
+	}
+	return nil
+}
+
+// diffContentFormat checks that the planned content of a filesystem_file resource is well-formed
+// according to its validate_format attribute, or the current file content if only the format
+// changes
+func (p filesystemProvider) diffContentFormat(path string, s *terraform.InstanceState, diff *terraform.InstanceDiff) error {
+	var format string
+	if s != nil {
+		format = s.Attributes["validate_format"]
+	}
+	if formatDiff, ok := diff.Attributes["validate_format"]; ok {
+		format = formatDiff.New
+	}
+
+	if format == "" {
+		return nil
+	}
+
+	for _, attribute := range append(digestContentAttributes, "content") {
+		attrDiff, ok := diff.Attributes[attribute]
+		if !ok || attrDiff.NewComputed || attrDiff.NewRemoved {
+			continue
+		}
+
+		content, ok := attrDiff.NewExtra.(string)
+		if !ok || content == "" && attribute != "content" {
+			continue
+		}
+
+		switch attribute {
+		case "content_json":
+			content, _ = canonicalJSON(content)
+		case "content_yaml":
+			content, _ = canonicalYAML(content)
+		}
+
+		return checkContentFormat(attribute, format, content)
+	}
+
+	if _, ok := diff.Attributes["validate_format"]; !ok || diff.RequiresNew() {
+		return nil
+	}
+
+	path, err := p.resolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	return checkContentFormat("content", format, content)
+}
@@ -0,0 +1,80 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateContentFormat(t *testing.T) {
+	cases := map[string]struct {
+		Format   string
+		Content  string
+		Expected string
+	}{
+		"json": {
+			Format:  "json",
+			Content: "{\"a\": [1, 2]}\n",
+		},
+		"json invalid": {
+			Format:   "json",
+			Content:  "{\n\"a\": [1, 2\n}",
+			Expected: "invalid JSON: line 3",
+		},
+		"json trailing data": {
+			Format:   "json",
+			Content:  "{} {}",
+			Expected: "invalid JSON: unexpected data after top-level value",
+		},
+		"yaml": {
+			Format:  "yaml",
+			Content: "a: 1\n---\nb: [1, 2]\n",
+		},
+		"yaml invalid": {
+			Format:   "yaml",
+			Content:  "a: 1\nb: [1, 2\n",
+			Expected: "invalid YAML",
+		},
+		"toml": {
+			Format:  "toml",
+			Content: "a = 1\n[b]\nc = \"d\"\n",
+		},
+		"toml invalid": {
+			Format:   "toml",
+			Content:  "a = 1\nb = c\n",
+			Expected: "invalid TOML: toml: line 2",
+		},
+		"ini": {
+			Format:  "ini",
+			Content: "a = 1\n[b]\nc = d\n",
+		},
+		"ini invalid": {
+			Format:   "ini",
+			Content:  "[b\nc = d\n",
+			Expected: "invalid INI",
+		},
+		"xml": {
+			Format:  "xml",
+			Content: "<?xml version=\"1.0\"?>\n<a><b c=\"d\"/></a>\n",
+		},
+		"xml invalid": {
+			Format:   "xml",
+			Content:  "<a>\n<b></a>\n",
+			Expected: "invalid XML: XML syntax error on line 2",
+		},
+		"xml several roots": {
+			Format:   "xml",
+			Content:  "<a/><b/>",
+			Expected: "invalid XML: 2 root elements instead of 1",
+		},
+	}
+
+	for name, tc := range cases {
+		err := validateContentFormat(tc.Format, tc.Content)
+		if tc.Expected == "" && err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if tc.Expected != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.Expected)) {
+			t.Fatalf("%s: error (%v) different from expected error (%q)", name, err, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package filesystem
+
+import (
+	"net"
+	"strings"
+)
+
+// The following functions edit hosts files (see hosts(5)) line by line, so that comments,
+// unrelated entries and alignment are preserved. Hostnames are compared case-insensitively.
+
+// hostsField is a whitespace-separated field of a hosts file line, comments excluded
+type hostsField struct {
+	start, end int
+}
+
+// hostsFields returns the fields of a hosts file line
+func hostsFields(line string) []hostsField {
+	if comment := strings.IndexByte(line, '#'); comment >= 0 {
+		line = line[:comment]
+	}
+
+	var fields []hostsField
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' || line[i] == '\r' {
+			i++
+			continue
+		}
+
+		field := hostsField{start: i}
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' && line[i] != '\r' {
+			i++
+		}
+		field.end = i
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// hostsLineIP reports whether a hosts file line is an entry of the given IP address
+func hostsLineIP(line string, ip net.IP) bool {
+	fields := hostsFields(line)
+	return len(fields) > 0 && ip.Equal(net.ParseIP(line[fields[0].start:fields[0].end]))
+}
+
+// hostsGet returns the hostnames of the entries of an IP address
+func hostsGet(content string, ip net.IP) []string {
+	lines, _ := splitLines(content)
+
+	var names []string
+	for _, line := range lines {
+		if !hostsLineIP(line, ip) {
+			continue
+		}
+
+		for _, field := range hostsFields(line)[1:] {
+			names = append(names, line[field.start:field.end])
+		}
+	}
+	return names
+}
+
+// hostsMissing returns the hostnames not mapped to an IP address, without duplicates
+func hostsMissing(content string, ip net.IP, names []string) []string {
+	existing := hostsGet(content, ip)
+
+	var missing []string
+	for _, name := range names {
+		if !containsFold(existing, name) && !containsFold(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// hostsSet adds the missing hostnames of an IP address to its first entry, the entry being added at
+// the end of the content if missing, with the separator of the last entry (a tab by default)
+func hostsSet(content string, ip net.IP, names []string) string {
+	lines, newline := splitLines(content)
+
+	missing := hostsMissing(content, ip, names)
+	if len(missing) == 0 {
+		return content
+	}
+
+	separator := "\t"
+	for i, line := range lines {
+		fields := hostsFields(line)
+		if len(fields) == 0 || net.ParseIP(line[fields[0].start:fields[0].end]) == nil {
+			continue
+		}
+
+		if !ip.Equal(net.ParseIP(line[fields[0].start:fields[0].end])) {
+			if len(fields) > 1 {
+				separator = line[fields[0].end:fields[1].start]
+			}
+			continue
+		}
+
+		// Entries left with their comment only get the names after their IP address
+		if len(fields) == 1 {
+			lines[i] = line[:fields[0].end] + separator + strings.Join(missing, " ") + line[fields[0].end:]
+			return joinLines(lines, newline)
+		}
+
+		// Names are added after the last one, with the separator of the previous names
+		last, nameSeparator := fields[len(fields)-1], " "
+		if len(fields) > 2 {
+			nameSeparator = line[fields[len(fields)-2].end:last.start]
+		}
+		lines[i] = line[:last.end] + nameSeparator + strings.Join(missing, nameSeparator) + line[last.end:]
+		return joinLines(lines, newline)
+	}
+
+	return joinLines(append(lines, ip.String()+separator+strings.Join(missing, " ")), newline)
+}
+
+// hostsRemove removes hostnames from the entries of an IP address, along with the entries left
+// without hostnames nor comment
+func hostsRemove(content string, ip net.IP, names []string) string {
+	lines, newline := splitLines(content)
+
+	var edited []string
+	changed := false
+	for _, line := range lines {
+		if !hostsLineIP(line, ip) {
+			edited = append(edited, line)
+			continue
+		}
+
+		fields := hostsFields(line)
+		kept, removed := len(fields)-1, false
+
+		// Names are removed from the last one, along with their following separator, or their
+		// preceding one when no name follows, so that the alignment of the entry is preserved
+		next := -1
+		for i := len(fields) - 1; i > 0; i-- {
+			if !containsFold(names, line[fields[i].start:fields[i].end]) {
+				next = i
+				continue
+			}
+
+			if next > 0 {
+				line = line[:fields[i].start] + line[fields[next].start:]
+				fields[next].start = fields[i].start
+			} else {
+				line = line[:fields[i-1].end] + line[fields[i].end:]
+			}
+			kept, removed = kept-1, true
+		}
+
+		if kept > 0 || !removed || strings.IndexByte(line, '#') >= 0 {
+			edited = append(edited, line)
+		}
+		changed = changed || removed
+	}
+
+	if !changed {
+		return content
+	}
+	return joinLines(edited, newline)
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"net"
+	"testing"
+)
+
+const testHostsContent = `# static table lookup for hostnames
+127.0.0.1	localhost
+::1		localhost ip6-localhost ip6-loopback
+10.0.0.1	db db.internal # primary
+`
+
+func TestHostsSet(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		IP       string
+		Names    []string
+		Expected string
+	}{
+		"merged": {
+			IP:       "10.0.0.1",
+			Names:    []string{"DB", "postgres", "pg"},
+			Expected: "# static table lookup for hostnames\n127.0.0.1\tlocalhost\n::1\t\tlocalhost ip6-localhost ip6-loopback\n10.0.0.1\tdb db.internal postgres pg # primary\n",
+		},
+		"merged single name": {
+			IP:       "127.0.0.1",
+			Names:    []string{"app"},
+			Expected: "# static table lookup for hostnames\n127.0.0.1\tlocalhost app\n::1\t\tlocalhost ip6-localhost ip6-loopback\n10.0.0.1\tdb db.internal # primary\n",
+		},
+		"merged IPv6": {
+			IP:       "0:0::1",
+			Names:    []string{"ip6-localhost", "app"},
+			Expected: "# static table lookup for hostnames\n127.0.0.1\tlocalhost\n::1\t\tlocalhost ip6-localhost ip6-loopback app\n10.0.0.1\tdb db.internal # primary\n",
+		},
+		"added": {
+			IP:       "10.0.0.2",
+			Names:    []string{"cache", "redis"},
+			Expected: testHostsContent + "10.0.0.2\tcache redis\n",
+		},
+		"added to entry left with its comment": {
+			Content:  "127.0.0.1\tlocalhost\n10.0.0.1 # primary\n",
+			IP:       "10.0.0.1",
+			Names:    []string{"db"},
+			Expected: "127.0.0.1\tlocalhost\n10.0.0.1\tdb # primary\n",
+		},
+		"unchanged": {
+			IP:       "10.0.0.1",
+			Names:    []string{"db.internal"},
+			Expected: testHostsContent,
+		},
+	}
+
+	for name, tc := range cases {
+		if tc.Content == "" {
+			tc.Content = testHostsContent
+		}
+
+		content := hostsSet(tc.Content, net.ParseIP(tc.IP), tc.Names)
+		if content != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, content, tc.Expected)
+		}
+	}
+}
+
+func TestHostsRemove(t *testing.T) {
+	cases := map[string]struct {
+		IP       string
+		Names    []string
+		Expected string
+	}{
+		"names removed": {
+			IP:       "::1",
+			Names:    []string{"localhost", "ip6-loopback"},
+			Expected: "# static table lookup for hostnames\n127.0.0.1\tlocalhost\n::1\t\tip6-localhost\n10.0.0.1\tdb db.internal # primary\n",
+		},
+		"consecutive names removed": {
+			IP:       "::1",
+			Names:    []string{"localhost", "ip6-localhost"},
+			Expected: "# static table lookup for hostnames\n127.0.0.1\tlocalhost\n::1\t\tip6-loopback\n10.0.0.1\tdb db.internal # primary\n",
+		},
+		"entry removed": {
+			IP:       "127.0.0.1",
+			Names:    []string{"localhost"},
+			Expected: "# static table lookup for hostnames\n::1\t\tlocalhost ip6-localhost ip6-loopback\n10.0.0.1\tdb db.internal # primary\n",
+		},
+		"entry left with its comment": {
+			IP:       "10.0.0.1",
+			Names:    []string{"db.internal", "DB"},
+			Expected: "# static table lookup for hostnames\n127.0.0.1\tlocalhost\n::1\t\tlocalhost ip6-localhost ip6-loopback\n10.0.0.1 # primary\n",
+		},
+		"unchanged": {
+			IP:       "10.0.0.2",
+			Names:    []string{"db"},
+			Expected: testHostsContent,
+		},
+	}
+
+	for name, tc := range cases {
+		content := hostsRemove(testHostsContent, net.ParseIP(tc.IP), tc.Names)
+		if content != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, content, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package filesystem
+
+import "strings"
+
+// The following functions edit INI files line by line, so that comments, ordering and formatting
+// are preserved. Keys are looked up in a section, the keys before the first section header being
+// in the "" section. Values are read and written verbatim, apart from their trailing inline comment
+// (starting with " ;" or " #"), which is kept when the value is replaced.
+
+// iniSectionHeader returns the section name of a section header line
+func iniSectionHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// iniComment returns the offset of the inline comment of line after offset, including the
+// whitespace preceding it, or the offset of its trailing whitespace if it has no inline comment
+func iniComment(line string, offset int) int {
+	end := len(strings.TrimRight(line, " \t"))
+	for i := offset; i < end; i++ {
+		if (line[i] == ';' || line[i] == '#') && i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') {
+			end = len(strings.TrimRight(line[:i], " \t"))
+			break
+		}
+	}
+	if end < offset {
+		return offset
+	}
+	return end
+}
+
+// iniKey returns the key of a key line, along with the offset of its value in the line (-1 for
+// keys without value, e.g. my.cnf flags)
+func iniKey(line string) (string, int, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.ContainsAny(trimmed[:1], ";#[") {
+		return "", 0, false
+	}
+
+	end := iniComment(line, 0)
+	i := strings.IndexAny(line[:end], "=:")
+	if i < 0 {
+		return strings.TrimSpace(line[:end]), -1, true
+	}
+
+	offset := i + 1
+	for offset < len(line) && (line[offset] == ' ' || line[offset] == '\t') {
+		offset++
+	}
+
+	return strings.TrimSpace(line[:i]), offset, true
+}
+
+// iniFind returns the index of the line of the first occurrence of key in section (-1 if
+// missing), and the index of the line after which the key is to be inserted (the last key line of
+// the section, or its header, -1 for the "" section without keys) if the section exists
+func iniFind(lines []string, section, key string) (keyLine, lastLine int, sectionFound bool) {
+	keyLine, lastLine, sectionFound = -1, -1, section == ""
+	current := ""
+
+	for i, line := range lines {
+		if name, ok := iniSectionHeader(line); ok {
+			current = name
+			if current == section && !sectionFound {
+				sectionFound, lastLine = true, i
+			}
+			continue
+		}
+
+		if current != section {
+			continue
+		}
+
+		if name, _, ok := iniKey(line); ok {
+			lastLine = i
+			if name == key && keyLine < 0 {
+				keyLine = i
+			}
+		}
+	}
+
+	return
+}
+
+// iniGet returns the value of key in section, and whether the key exists
+func iniGet(content, section, key string) (string, bool) {
+	lines, _ := splitLines(content)
+
+	keyLine, _, _ := iniFind(lines, section, key)
+	if keyLine < 0 {
+		return "", false
+	}
+
+	line := lines[keyLine]
+	_, offset, _ := iniKey(line)
+	if offset < 0 {
+		return "", true
+	}
+	return line[offset:iniComment(line, offset)], true
+}
+
+// iniSet sets the value of key in section, adding the key after the last key of the section if
+// missing, and the section at the end of the content if missing
+func iniSet(content, section, key, value string) string {
+	lines, newline := splitLines(content)
+
+	keyLine, lastLine, sectionFound := iniFind(lines, section, key)
+	switch {
+	case keyLine >= 0:
+		line := lines[keyLine]
+		if _, offset, _ := iniKey(line); offset >= 0 {
+			end := iniComment(line, offset)
+			lines[keyLine] = line[:offset] + value + strings.TrimRight(line[end:], " \t")
+		} else {
+			end := iniComment(line, 0)
+			lines[keyLine] = line[:end] + " = " + value + strings.TrimRight(line[end:], " \t")
+		}
+
+	case sectionFound:
+		lines = append(lines[:lastLine+1], append([]string{key + " = " + value}, lines[lastLine+1:]...)...)
+
+	default:
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", key+" = "+value)
+	}
+
+	return joinLines(lines, newline)
+}
+
+// iniDelete removes the first occurrence of key in section
+func iniDelete(content, section, key string) string {
+	lines, newline := splitLines(content)
+
+	keyLine, _, _ := iniFind(lines, section, key)
+	if keyLine < 0 {
+		return content
+	}
+
+	return joinLines(append(lines[:keyLine], lines[keyLine+1:]...), newline)
+}
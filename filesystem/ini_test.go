@@ -0,0 +1,194 @@
+package filesystem
+
+import "testing"
+
+const testINIContent = `; global settings
+engine = On
+
+[mysqld]
+# networking
+port = 3306
+skip-name-resolve
+
+[client]
+port=3306
+`
+
+func TestINISet(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Section  string
+		Key      string
+		Value    string
+		Expected string
+	}{
+		"replaced": {
+			Content:  testINIContent,
+			Section:  "mysqld",
+			Key:      "port",
+			Value:    "3307",
+			Expected: "; global settings\nengine = On\n\n[mysqld]\n# networking\nport = 3307\nskip-name-resolve\n\n[client]\nport=3306\n",
+		},
+		"replaced without spaces": {
+			Content:  testINIContent,
+			Section:  "client",
+			Key:      "port",
+			Value:    "3307",
+			Expected: "; global settings\nengine = On\n\n[mysqld]\n# networking\nport = 3306\nskip-name-resolve\n\n[client]\nport=3307\n",
+		},
+		"added to flag": {
+			Content:  testINIContent,
+			Section:  "mysqld",
+			Key:      "skip-name-resolve",
+			Value:    "1",
+			Expected: "; global settings\nengine = On\n\n[mysqld]\n# networking\nport = 3306\nskip-name-resolve = 1\n\n[client]\nport=3306\n",
+		},
+		"added to section": {
+			Content:  testINIContent,
+			Section:  "mysqld",
+			Key:      "bind-address",
+			Value:    "127.0.0.1",
+			Expected: "; global settings\nengine = On\n\n[mysqld]\n# networking\nport = 3306\nskip-name-resolve\nbind-address = 127.0.0.1\n\n[client]\nport=3306\n",
+		},
+		"added to default section": {
+			Content:  testINIContent,
+			Section:  "",
+			Key:      "short_open_tag",
+			Value:    "Off",
+			Expected: "; global settings\nengine = On\nshort_open_tag = Off\n\n[mysqld]\n# networking\nport = 3306\nskip-name-resolve\n\n[client]\nport=3306\n",
+		},
+		"added section": {
+			Content:  testINIContent,
+			Section:  "mysqldump",
+			Key:      "quick",
+			Value:    "1",
+			Expected: testINIContent + "\n[mysqldump]\nquick = 1\n",
+		},
+		"empty content": {
+			Content:  "",
+			Section:  "a",
+			Key:      "b",
+			Value:    "c",
+			Expected: "[a]\nb = c\n",
+		},
+		"inline comment": {
+			Content:  "[mysqld]\nport = 3306 ; primary\nskip-name-resolve\t# no DNS\n",
+			Section:  "mysqld",
+			Key:      "port",
+			Value:    "3307",
+			Expected: "[mysqld]\nport = 3307 ; primary\nskip-name-resolve\t# no DNS\n",
+		},
+		"added to flag with inline comment": {
+			Content:  "[mysqld]\nport = 3306 ; primary\nskip-name-resolve\t# no DNS\n",
+			Section:  "mysqld",
+			Key:      "skip-name-resolve",
+			Value:    "1",
+			Expected: "[mysqld]\nport = 3306 ; primary\nskip-name-resolve = 1\t# no DNS\n",
+		},
+		"crlf": {
+			Content:  "[a]\r\nb = c\r\n",
+			Section:  "a",
+			Key:      "d",
+			Value:    "e",
+			Expected: "[a]\r\nb = c\r\nd = e\r\n",
+		},
+	}
+
+	for name, tc := range cases {
+		if content := iniSet(tc.Content, tc.Section, tc.Key, tc.Value); content != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, content, tc.Expected)
+		}
+
+		if value, ok := iniGet(iniSet(tc.Content, tc.Section, tc.Key, tc.Value), tc.Section, tc.Key); !ok || value != tc.Value {
+			t.Fatalf("%s: value (%q) different from expected value (%q)", name, value, tc.Value)
+		}
+	}
+}
+
+func TestINIGet(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Key      string
+		Expected string
+		Exists   bool
+	}{
+		"value": {
+			Content:  "port = 3306\n",
+			Key:      "port",
+			Expected: "3306",
+			Exists:   true,
+		},
+		"inline comment": {
+			Content:  "port = 3306 ; primary\n",
+			Key:      "port",
+			Expected: "3306",
+			Exists:   true,
+		},
+		"hash inline comment": {
+			Content:  "port=3306\t# primary\n",
+			Key:      "port",
+			Expected: "3306",
+			Exists:   true,
+		},
+		"comment character in value": {
+			Content:  "path = a;b#c\n",
+			Key:      "path",
+			Expected: "a;b#c",
+			Exists:   true,
+		},
+		"empty value with inline comment": {
+			Content:  "port = ; primary\n",
+			Key:      "port",
+			Expected: "",
+			Exists:   true,
+		},
+		"flag with inline comment": {
+			Content:  "skip-name-resolve ; no DNS=1\n",
+			Key:      "skip-name-resolve",
+			Expected: "",
+			Exists:   true,
+		},
+		"missing": {
+			Content: "port = 3306\n",
+			Key:     "socket",
+		},
+	}
+
+	for name, tc := range cases {
+		value, ok := iniGet(tc.Content, "", tc.Key)
+		if ok != tc.Exists {
+			t.Fatalf("%s: key existence (%t) different from expected existence (%t)", name, ok, tc.Exists)
+		}
+		if value != tc.Expected {
+			t.Fatalf("%s: value (%q) different from expected value (%q)", name, value, tc.Expected)
+		}
+	}
+}
+
+func TestINIDelete(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Section  string
+		Key      string
+		Expected string
+	}{
+		"removed": {
+			Content:  testINIContent,
+			Section:  "mysqld",
+			Key:      "port",
+			Expected: "; global settings\nengine = On\n\n[mysqld]\n# networking\nskip-name-resolve\n\n[client]\nport=3306\n",
+		},
+		"missing": {
+			Content:  testINIContent,
+			Section:  "client",
+			Key:      "socket",
+			Expected: testINIContent,
+		},
+	}
+
+	for name, tc := range cases {
+		if content := iniDelete(tc.Content, tc.Section, tc.Key); content != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, content, tc.Expected)
+		}
+	}
+}
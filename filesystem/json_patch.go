@@ -0,0 +1,516 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The following functions edit JSON documents while preserving the order of the object keys, so
+// that edits leave the rest of the documents unchanged (but for their indentation). Objects are
+// decoded as *jsonObject, arrays as []interface{}, and numbers as json.Number.
+
+// jsonObject is a JSON object preserving the order of its keys
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]interface{}{}}
+}
+
+func (o *jsonObject) get(key string) (interface{}, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// set sets the value of key, new keys being added after the existing ones
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) remove(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// decodeOrderedJSON decodes a JSON document, preserving the order of object keys
+func decodeOrderedJSON(s string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	value, err := decodeOrderedJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %s", err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	return value, nil
+}
+
+func decodeOrderedJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := newJSONObject()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeOrderedJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key.(string), value)
+		}
+		_, err := decoder.Token()
+		return object, err
+
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrderedJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := decoder.Token()
+		return array, err
+	}
+
+	return token, nil
+}
+
+// encodeOrderedJSON encodes a JSON value decoded by decodeOrderedJSON, indented with indent (on a
+// single line if empty)
+func encodeOrderedJSON(value interface{}, indent string) (string, error) {
+	var buffer bytes.Buffer
+	if err := encodeOrderedJSONValue(&buffer, value, indent, "\n"); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func encodeOrderedJSONValue(buffer *bytes.Buffer, value interface{}, indent, newline string) error {
+	separator, nextNewline := ":", newline+indent
+	if indent != "" {
+		separator = ": "
+	} else {
+		newline, nextNewline = "", ""
+	}
+
+	switch v := value.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			buffer.WriteString("{}")
+			return nil
+		}
+
+		buffer.WriteString("{")
+		for i, key := range v.keys {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(nextNewline)
+
+			if err := encodeOrderedJSONValue(buffer, key, indent, nextNewline); err != nil {
+				return err
+			}
+			buffer.WriteString(separator)
+
+			if err := encodeOrderedJSONValue(buffer, v.values[key], indent, nextNewline); err != nil {
+				return err
+			}
+		}
+		buffer.WriteString(newline + "}")
+
+	case []interface{}:
+		if len(v) == 0 {
+			buffer.WriteString("[]")
+			return nil
+		}
+
+		buffer.WriteString("[")
+		for i, element := range v {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(nextNewline)
+
+			if err := encodeOrderedJSONValue(buffer, element, indent, nextNewline); err != nil {
+				return err
+			}
+		}
+		buffer.WriteString(newline + "]")
+
+	default:
+		var scalar bytes.Buffer
+		encoder := json.NewEncoder(&scalar)
+		encoder.SetEscapeHTML(false)
+
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		buffer.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+	}
+
+	return nil
+}
+
+// jsonIndentRegexp matches the indentation of the first indented line of a JSON document
+var jsonIndentRegexp = regexp.MustCompile(`\n([ \t]+)\S`)
+
+// editJSON applies edit to the JSON document held by content (an empty object if content is
+// empty), the edited document keeping the indentation of content (two spaces by default)
+func editJSON(content string, edit func(interface{}) (interface{}, error)) (string, error) {
+	var document interface{} = newJSONObject()
+	if strings.TrimSpace(content) != "" {
+		var err error
+		if document, err = decodeOrderedJSON(content); err != nil {
+			return "", err
+		}
+	}
+
+	document, err := edit(document)
+	if err != nil {
+		return "", err
+	}
+
+	indent := "  "
+	if match := jsonIndentRegexp.FindStringSubmatch(content); match != nil {
+		indent = match[1]
+	}
+
+	edited, err := encodeOrderedJSON(document, indent)
+	if err != nil {
+		return "", err
+	}
+
+	if content == "" || strings.HasSuffix(content, "\n") {
+		edited += "\n"
+	}
+
+	return edited, nil
+}
+
+// equivalentJSON reports whether two JSON documents hold the same values
+func equivalentJSON(a, b string) bool {
+	var values [2]interface{}
+	for i, s := range []string{a, b} {
+		decoder := json.NewDecoder(strings.NewReader(s))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&values[i]); err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(values[0], values[1])
+}
+
+// parseJSONPointer returns the reference tokens of a RFC 6901 JSON pointer, the whole document
+// not being addressable
+func parseJSONPointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+// jsonArrayIndex returns the array index of a reference token, up to max
+func jsonArrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || strconv.Itoa(index) != token {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+// lessTokens reports whether the tokens of a path sort before the tokens of another one, tokens
+// being compared one by one, numerically when both are array indexes
+func lessTokens(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		if errX == nil && errY == nil && x != y {
+			return x < y
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
+
+// jsonPointerGet returns the value referenced by the tokens of a JSON pointer, and whether it
+// exists
+func jsonPointerGet(node interface{}, tokens []string) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return node, true
+	}
+
+	switch n := node.(type) {
+	case *jsonObject:
+		if child, ok := n.get(tokens[0]); ok {
+			return jsonPointerGet(child, tokens[1:])
+		}
+
+	case []interface{}:
+		if index, err := jsonArrayIndex(tokens[0], len(n)-1); err == nil {
+			return jsonPointerGet(n[index], tokens[1:])
+		}
+	}
+
+	return nil, false
+}
+
+// jsonPointerSet sets the value referenced by the tokens of a JSON pointer, creating the missing
+// objects on its path, and returns the updated node. Array elements can be replaced, or appended
+// with the index following the last element.
+func jsonPointerSet(node interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	switch n := node.(type) {
+	case nil:
+		return jsonPointerSet(newJSONObject(), tokens, value)
+
+	case *jsonObject:
+		child, _ := n.get(tokens[0])
+		child, err := jsonPointerSet(child, tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n.set(tokens[0], child)
+		return n, nil
+
+	case []interface{}:
+		index, err := jsonArrayIndex(tokens[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+
+		if index == len(n) {
+			n = append(n, nil)
+		}
+
+		if n[index], err = jsonPointerSet(n[index], tokens[1:], value); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("%q: parent value is neither an object nor an array", tokens[0])
+}
+
+// jsonPointerRemove removes the value referenced by the tokens of a JSON pointer, along with the
+// objects left empty by the removal, and returns the updated node and whether the value existed
+func jsonPointerRemove(node interface{}, tokens []string) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return node, false
+	}
+
+	switch n := node.(type) {
+	case *jsonObject:
+		child, ok := n.get(tokens[0])
+		if !ok {
+			return n, false
+		}
+
+		if len(tokens) == 1 {
+			n.remove(tokens[0])
+			return n, true
+		}
+
+		child, removed := jsonPointerRemove(child, tokens[1:])
+		if object, ok := child.(*jsonObject); removed && ok && len(object.keys) == 0 {
+			n.remove(tokens[0])
+		} else {
+			n.set(tokens[0], child)
+		}
+		return n, removed
+
+	case []interface{}:
+		index, err := jsonArrayIndex(tokens[0], len(n)-1)
+		if err != nil {
+			return n, false
+		}
+
+		if len(tokens) == 1 {
+			return append(n[:index], n[index+1:]...), true
+		}
+
+		var removed bool
+		n[index], removed = jsonPointerRemove(n[index], tokens[1:])
+		return n, removed
+	}
+
+	return node, false
+}
+
+// mergePatch applies a RFC 7386 JSON merge patch to a JSON value, and returns the patched value
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(*jsonObject)
+	if !ok {
+		targetObject = newJSONObject()
+	}
+
+	for _, key := range patchObject.keys {
+		if value := patchObject.values[key]; value == nil {
+			targetObject.remove(key)
+		} else {
+			child, _ := targetObject.get(key)
+			targetObject.set(key, mergePatch(child, value))
+		}
+	}
+
+	return targetObject
+}
+
+// mergePatchPointers returns the JSON pointers of the values set or removed by a JSON merge patch,
+// relative to prefix
+func mergePatchPointers(patch interface{}, prefix string) []string {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok || len(patchObject.keys) == 0 {
+		return []string{prefix}
+	}
+
+	var pointers []string
+	for _, key := range patchObject.keys {
+		token := strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+		pointers = append(pointers, mergePatchPointers(patchObject.values[key], prefix+"/"+token)...)
+	}
+	return pointers
+}
+
+// mergePatchProjection returns the part of a JSON value managed by a JSON merge patch, which
+// equals the patch when the patch is applied (keys removed by the patch being projected as null)
+func mergePatchProjection(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok {
+		return target
+	}
+
+	targetObject, ok := target.(*jsonObject)
+	if !ok {
+		return target
+	}
+
+	projection := newJSONObject()
+	for _, key := range patchObject.keys {
+		if child, ok := targetObject.get(key); ok {
+			projection.set(key, mergePatchProjection(child, patchObject.values[key]))
+		} else if patchObject.values[key] == nil {
+			projection.set(key, nil)
+		}
+	}
+
+	return projection
+}
+
+// mergePatchRemove removes the keys set by a JSON merge patch from a JSON value, along with the
+// objects left empty by the removal, and returns the updated value
+func mergePatchRemove(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok {
+		return target
+	}
+
+	targetObject, ok := target.(*jsonObject)
+	if !ok {
+		return target
+	}
+
+	for _, key := range patchObject.keys {
+		value := patchObject.values[key]
+		child, ok := targetObject.get(key)
+		if value == nil || !ok {
+			continue
+		}
+
+		childObject, childIsObject := child.(*jsonObject)
+		if _, ok := value.(*jsonObject); !ok || !childIsObject {
+			targetObject.remove(key)
+			continue
+		}
+
+		if mergePatchRemove(childObject, value); len(childObject.keys) == 0 {
+			targetObject.remove(key)
+		}
+	}
+
+	return targetObject
+}
+
+// mergePatchExclude returns a JSON merge patch setting the keys set by patch but not by exclude
+func mergePatchExclude(patch, exclude interface{}) interface{} {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok {
+		return patch
+	}
+
+	excludeObject, ok := exclude.(*jsonObject)
+	if !ok {
+		return patch
+	}
+
+	difference := newJSONObject()
+	for _, key := range patchObject.keys {
+		value := patchObject.values[key]
+		excluded, ok := excludeObject.get(key)
+		if !ok {
+			difference.set(key, value)
+			continue
+		}
+
+		_, valueIsObject := value.(*jsonObject)
+		if _, ok := excluded.(*jsonObject); valueIsObject && ok {
+			if child := mergePatchExclude(value, excluded).(*jsonObject); len(child.keys) > 0 {
+				difference.set(key, child)
+			}
+		}
+	}
+
+	return difference
+}
@@ -0,0 +1,152 @@
+package filesystem
+
+import "testing"
+
+func TestEditJSON(t *testing.T) {
+	const content = "{\n    \"b\": 1,\n    \"a\": {\"d\": [1, 2], \"c\": \"<x>\"}\n}\n"
+
+	cases := map[string]struct {
+		Content  string
+		Pointer  string
+		Value    string
+		Remove   bool
+		Expected string
+	}{
+		"unchanged": {
+			Content:  content,
+			Pointer:  "/b",
+			Value:    "1",
+			Expected: "{\n    \"b\": 1,\n    \"a\": {\n        \"d\": [\n            1,\n            2\n        ],\n        \"c\": \"<x>\"\n    }\n}\n",
+		},
+		"added": {
+			Content:  content,
+			Pointer:  "/a/e~1f",
+			Value:    "{\"h\": null, \"g\": true}",
+			Expected: "{\n    \"b\": 1,\n    \"a\": {\n        \"d\": [\n            1,\n            2\n        ],\n        \"c\": \"<x>\",\n        \"e/f\": {\n            \"h\": null,\n            \"g\": true\n        }\n    }\n}\n",
+		},
+		"array element": {
+			Content:  "[1, 2]",
+			Pointer:  "/2",
+			Value:    "3",
+			Expected: "[\n  1,\n  2,\n  3\n]",
+		},
+		"created": {
+			Content:  "",
+			Pointer:  "/a/b",
+			Value:    "\"c\"",
+			Expected: "{\n  \"a\": {\n    \"b\": \"c\"\n  }\n}\n",
+		},
+		"removed": {
+			Content:  "{\n  \"a\": {\"b\": 1},\n  \"c\": 2\n}\n",
+			Pointer:  "/a/b",
+			Remove:   true,
+			Expected: "{\n  \"c\": 2\n}\n",
+		},
+		"removed missing": {
+			Content:  "{\"c\": 2}",
+			Pointer:  "/a/b",
+			Remove:   true,
+			Expected: "{\n  \"c\": 2\n}",
+		},
+	}
+
+	for name, tc := range cases {
+		tokens, err := parseJSONPointer(tc.Pointer)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		edited, err := editJSON(tc.Content, func(document interface{}) (interface{}, error) {
+			if tc.Remove {
+				document, _ = jsonPointerRemove(document, tokens)
+				return document, nil
+			}
+
+			value, err := decodeOrderedJSON(tc.Value)
+			if err != nil {
+				return nil, err
+			}
+			return jsonPointerSet(document, tokens, value)
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if edited != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, edited, tc.Expected)
+		}
+	}
+}
+
+func TestJSONPatchRemove(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Pointers []string
+		Expected string
+	}{
+		"array elements": {
+			Content:  "[0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11]",
+			Pointers: []string{"/9", "/10"},
+			Expected: "[\n  0,\n  1,\n  2,\n  3,\n  4,\n  5,\n  6,\n  7,\n  8,\n  11\n]",
+		},
+		"nested array elements": {
+			Content:  "{\"a\": [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11], \"b\": 1}",
+			Pointers: []string{"/a/10", "/b", "/a/9", "/a/2"},
+			Expected: "{\n  \"a\": [\n    0,\n    1,\n    3,\n    4,\n    5,\n    6,\n    7,\n    8,\n    11\n  ]\n}",
+		},
+	}
+
+	for name, tc := range cases {
+		edited, err := editJSON(tc.Content, jsonPatchRemove(tc.Pointers, nil))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if edited != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, edited, tc.Expected)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	cases := map[string]struct {
+		Target     string
+		Patch      string
+		Expected   string
+		Projection string
+		Removed    string
+	}{
+		"merged": {
+			Target:     `{"a":"b","c":{"d":"e","f":"g"},"h":1}`,
+			Patch:      `{"a":"z","c":{"f":null,"i":[1]}}`,
+			Expected:   `{"a":"z","c":{"d":"e","i":[1]},"h":1}`,
+			Projection: `{"a":"z","c":{"f":null,"i":[1]}}`,
+			Removed:    `{"c":{"d":"e"},"h":1}`,
+		},
+		"added": {
+			Target:     `{"h":1}`,
+			Patch:      `{"a":{"b":"c"}}`,
+			Expected:   `{"h":1,"a":{"b":"c"}}`,
+			Projection: `{"a":{"b":"c"}}`,
+			Removed:    `{"h":1}`,
+		},
+	}
+
+	for name, tc := range cases {
+		target, _ := decodeOrderedJSON(tc.Target)
+		patch, _ := decodeOrderedJSON(tc.Patch)
+
+		patched := mergePatch(target, patch)
+		if result, _ := encodeOrderedJSON(patched, ""); result != tc.Expected {
+			t.Fatalf("%s: patched value (%s) different from expected value (%s)", name, result, tc.Expected)
+		}
+
+		if result, _ := encodeOrderedJSON(mergePatchProjection(patched, patch), ""); result != tc.Projection {
+			t.Fatalf("%s: projection (%s) different from expected projection (%s)", name, result, tc.Projection)
+		}
+
+		if result, _ := encodeOrderedJSON(mergePatchRemove(patched, patch), ""); result != tc.Removed {
+			t.Fatalf("%s: value (%s) different from expected value (%s)", name, result, tc.Removed)
+		}
+	}
+}
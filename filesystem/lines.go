@@ -0,0 +1,26 @@
+package filesystem
+
+import "strings"
+
+// splitLines splits text content into lines, along with its line separator (\r\n if the content
+// has any, \n otherwise), so that line-based edits preserve the rest of the content
+func splitLines(content string) ([]string, string) {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+
+	if content == "" {
+		return nil, newline
+	}
+
+	return strings.Split(strings.TrimSuffix(content, newline), newline), newline
+}
+
+// joinLines joins lines split by splitLines, terminating the last line
+func joinLines(lines []string, newline string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, newline) + newline
+}
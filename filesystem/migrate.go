@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// stateMigration upgrades an instance state from a schema version to the next one
+type stateMigration func(is *terraform.InstanceState, meta interface{}) error
+
+// migrateState returns a MigrateState function applying the migrations following the state schema
+// version in order: migrations[i] upgrades a state from version i to version i+1, the resource
+// SchemaVersion thus being the number of migrations
+func migrateState(migrations []stateMigration) schema.StateMigrateFunc {
+	return func(v int, is *terraform.InstanceState, meta interface{}) (*terraform.InstanceState, error) {
+		if is.Empty() {
+			return is, nil
+		}
+
+		if v < 0 || v > len(migrations) {
+			return is, fmt.Errorf("unexpected schema version: %d", v)
+		}
+
+		for version, migration := range migrations[v:] {
+			if err := migration(is, meta); err != nil {
+				return is, fmt.Errorf("unable to migrate state from schema version %d: %s", v+version, err)
+			}
+		}
+
+		return is, nil
+	}
+}
+
+// migrateStateV0toV1 replaces the path hash ID by the path itself
+func migrateStateV0toV1(is *terraform.InstanceState, meta interface{}) error {
+	path, ok := is.Attributes["path"]
+	if !ok {
+		return fmt.Errorf("missing path attribute")
+	}
+
+	is.ID = path
+
+	return nil
+}
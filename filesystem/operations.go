@@ -0,0 +1,232 @@
+package filesystem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The following functions perform the changes to the filesystem on behalf of the resources,
+// which must not change the filesystem otherwise in order for their changes to be audited and
+// rolled back in transactional mode.
+
+// change performs a change of the file at path, snapshotting the file beforehand (including its
+// content if the change affects it) in transactional mode, and recording the change in the audit
+// journal
+func (p filesystemProvider) change(operation, path string, contentChange bool, f func() error) error {
+	if p.transaction != nil {
+		p.transaction.changes.RLock()
+		defer p.transaction.changes.RUnlock()
+
+		if err := p.transaction.snapshot(p.ctx, path, contentChange); err != nil {
+			return err
+		}
+	}
+
+	before := p.auditState(path)
+
+	if err := f(); err != nil {
+		return err
+	}
+
+	return p.auditRecord(operation, path, before)
+}
+
+// writeFile writes content to the file at path, creating it if needed, and applies permissions.
+// The content is written to a temporary file renamed over the file, so that the file is never
+// left partially written, even when the operation is interrupted. If validate is not nil, it is
+// called with the path of the temporary file, and the file is only replaced if it succeeds.
+func (p filesystemProvider) writeFile(path string, content []byte, mode os.FileMode, validate func(string) error) error {
+	if err := interrupted(p.ctx); err != nil {
+		return err
+	}
+
+	// Content is written to the target of symbolic links, rather than replacing them
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	if err := p.checkHostPathPolicy(path); err != nil {
+		return err
+	}
+
+	operation := "write"
+	fileInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		operation = "create"
+	}
+
+	return p.change(operation, path, true, func() error {
+		file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+		if err != nil {
+			return err
+		}
+
+		if err := p.writeTempFile(file, content, mode, fileInfo); err != nil {
+			os.Remove(file.Name())
+			return err
+		}
+
+		if validate != nil {
+			if err := validate(file.Name()); err != nil {
+				os.Remove(file.Name())
+				return err
+			}
+		}
+
+		if err := os.Rename(file.Name(), path); err != nil {
+			os.Remove(file.Name())
+			return err
+		}
+
+		return nil
+	})
+}
+
+// editMutex serializes the edits of files whose content is managed by several resources
+var editMutex sync.Mutex
+
+// editFile replaces the content of the file at path with the result of edit, called with its
+// current content. Files are created with the given permissions if they don't exist, edit being
+// called with an empty content.
+func (p filesystemProvider) editFile(path string, mode os.FileMode, edit func(string) (string, error)) error {
+	editMutex.Lock()
+	defer editMutex.Unlock()
+
+	content, err := p.readFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if fileInfo, err := os.Stat(path); err == nil {
+		mode = fileInfo.Mode()
+	}
+
+	edited, err := edit(content)
+	if err != nil {
+		return err
+	}
+
+	if edited == content {
+		return nil
+	}
+
+	return p.writeFile(path, []byte(edited), mode, nil)
+}
+
+// writeTempFile writes content to a temporary file, applying the permissions and the owner of the
+// file it replaces (if any)
+func (p filesystemProvider) writeTempFile(file *os.File, content []byte, mode os.FileMode, replaced os.FileInfo) error {
+	// The permissions of temporary files are restricted to their owner
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		return err
+	}
+
+	if replaced != nil {
+		stat := replaced.Sys().(*syscall.Stat_t)
+		if err := file.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := copyContext(p.ctx, file, bytes.NewReader(content)); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// mkdir creates the directory at path with the given permissions, along with its missing parent
+// directories if requested
+func (p filesystemProvider) mkdir(path string, mode os.FileMode, parents bool) error {
+	dirs := []string{path}
+
+	if parents {
+		for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				break
+			}
+			dirs = append([]string{dir}, dirs...)
+		}
+	}
+
+	for _, dir := range dirs {
+		dir := dir
+
+		if err := interrupted(p.ctx); err != nil {
+			return err
+		}
+
+		// Parent directories have to be allowed as well
+		if err := p.checkHostPathPolicy(dir); err != nil {
+			return err
+		}
+
+		err := p.change("create", dir, false, func() error {
+			if err := os.Mkdir(dir, mode); err != nil {
+				return err
+			}
+
+			// The permissions passed to mkdir(2) are subject to the process umask
+			if dir == path {
+				return os.Chmod(dir, mode)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chmod, chown and chtimes follow symbolic links: their targets are checked against the path policy
+func (p filesystemProvider) chmod(path string, mode os.FileMode) error {
+	if err := p.checkHostPathPolicy(path); err != nil {
+		return err
+	}
+
+	return p.change("chmod", path, false, func() error {
+		return os.Chmod(path, mode)
+	})
+}
+
+func (p filesystemProvider) chown(path string, uid, gid int) error {
+	if err := p.checkHostPathPolicy(path); err != nil {
+		return err
+	}
+
+	return p.change("chown", path, false, func() error {
+		return os.Chown(path, uid, gid)
+	})
+}
+
+func (p filesystemProvider) chtimes(path string, atime, mtime time.Time) error {
+	if err := p.checkHostPathPolicy(path); err != nil {
+		return err
+	}
+
+	return p.change("chtimes", path, false, func() error {
+		return os.Chtimes(path, atime, mtime)
+	})
+}
+
+func (p filesystemProvider) remove(path string) error {
+	return p.change("remove", path, true, func() error {
+		return os.Remove(path)
+	})
+}
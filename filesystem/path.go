@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath checks a resource path and the target of its symbolic links against the provider path
+// policy, and returns its location on the host filesystem. It must be called before any filesystem
+// operation on a resource path.
+func (p filesystemProvider) resolvePath(path string) (string, error) {
+	if err := p.checkPathPolicy(path); err != nil {
+		return "", err
+	}
+
+	resolvedPath, err := p.resolveRootPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.checkHostPathPolicy(resolvedPath); err != nil {
+		return "", err
+	}
+
+	return resolvedPath, nil
+}
+
+// resolveRootPath returns the location of a path on the host filesystem, i.e. beneath the provider
+// root directory if one is configured. Paths escaping the root directory, either through ".."
+// elements or through symbolic links, are rejected.
+func (p filesystemProvider) resolveRootPath(path string) (string, error) {
+	if p.rootDir == "" {
+		return path, nil
+	}
+
+	depth := 0
+	for _, element := range strings.Split(filepath.ToSlash(path), "/") {
+		switch element {
+		case "", ".":
+		case "..":
+			if depth == 0 {
+				return "", fmt.Errorf("path %q escapes root directory %q", path, p.rootDir)
+			}
+			depth--
+		default:
+			depth++
+		}
+	}
+
+	resolvedPath := filepath.Join(p.rootDir, path)
+
+	// Symbolic links can only be resolved for the existing part of the path: look for the
+	// deepest existing ancestor (possibly the path itself) and make sure it stays in the root
+	existingPath := resolvedPath
+	for {
+		if _, err := os.Lstat(existingPath); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		existingPath = filepath.Dir(existingPath)
+	}
+
+	realPath, err := filepath.EvalSymlinks(existingPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve path %q: %s", path, err)
+	}
+
+	if !isWithinDir(realPath, p.rootDir) {
+		return "", fmt.Errorf("path %q escapes root directory %q through a symbolic link (%q -> %q)",
+			path,
+			p.rootDir,
+			existingPath,
+			realPath)
+	}
+
+	return resolvedPath, nil
+}
+
+func isWithinDir(path, dir string) bool {
+	if dir == string(filepath.Separator) {
+		return true
+	}
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkPathPolicy checks a resource path against the provider `allowed_paths` and `denied_paths`
+// glob patterns. A pattern matching a directory applies to everything beneath it, and denial
+// rules take precedence over allowing ones.
+func (p filesystemProvider) checkPathPolicy(path string) error {
+	if len(p.allowedPaths) == 0 && len(p.deniedPaths) == 0 {
+		return nil
+	}
+
+	path = filepath.Clean(path)
+
+	if rule := matchPathRule(path, p.deniedPaths); rule != "" {
+		return fmt.Errorf("path %q denied by denied_paths rule %q", path, rule)
+	}
+
+	if len(p.allowedPaths) > 0 && matchPathRule(path, p.allowedPaths) == "" {
+		return fmt.Errorf("path %q not allowed by any allowed_paths rule", path)
+	}
+
+	return nil
+}
+
+// checkHostPathPolicy checks a path of the host filesystem against the provider path policy once
+// its symbolic links are resolved, so that links can't be used to reach denied paths. Paths whose
+// symbolic links can't be resolved are rejected.
+func (p filesystemProvider) checkHostPathPolicy(path string) error {
+	if len(p.allowedPaths) == 0 && len(p.deniedPaths) == 0 {
+		return nil
+	}
+
+	// Symbolic links can only be resolved for the existing part of the path
+	existingPath, missingPath := path, ""
+	for {
+		if _, err := os.Lstat(existingPath); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		existingPath, missingPath = filepath.Dir(existingPath), filepath.Join(filepath.Base(existingPath), missingPath)
+	}
+
+	realPath, err := filepath.EvalSymlinks(existingPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve path %q: %s", path, err)
+	}
+	realPath = filepath.Join(realPath, missingPath)
+
+	// Rules apply to the paths beneath the root directory
+	if p.rootDir != "" {
+		if !isWithinDir(realPath, p.rootDir) {
+			return fmt.Errorf("path %q escapes root directory %q through a symbolic link", path, p.rootDir)
+		}
+		realPath = filepath.Join(string(filepath.Separator), strings.TrimPrefix(realPath, p.rootDir))
+	}
+
+	return p.checkPathPolicy(realPath)
+}
+
+// matchPathRule returns the first of the rules matching the path or one of its parent directories
+func matchPathRule(path string, rules []string) string {
+	for _, rule := range rules {
+		for p := path; ; p = filepath.Dir(p) {
+			if matched, _ := filepath.Match(rule, p); matched {
+				return rule
+			}
+
+			if p == filepath.Dir(p) {
+				break
+			}
+		}
+	}
+
+	return ""
+}
+
+func validatePathRules(key string, rules []string) error {
+	for _, rule := range rules {
+		if _, err := filepath.Match(rule, ""); err != nil {
+			return fmt.Errorf("%s: invalid rule %q: %s", key, rule, err)
+		}
+	}
+
+	return nil
+}
@@ -1,10 +1,13 @@
 package filesystem
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"os/user"
+	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/facette/logger"
 	"github.com/hashicorp/terraform/helper/schema"
@@ -12,72 +15,544 @@ import (
 )
 
 type filesystemProvider struct {
-	log *logger.Logger
+	ctx          context.Context
+	log          *logger.Logger
+	audit        *auditJournal
+	transaction  *transactionScope
+	rootDir      string
+	accounts     accountDatabase
+	allowedPaths []string
+	deniedPaths  []string
+
+	defaultUser     string
+	defaultGroup    string
+	defaultFileMode os.FileMode
+	defaultDirMode  os.FileMode
 }
 
-var providerLogFile = "terraform-provider-filesystem.log"
+// provider wraps the schema provider in order to check resource paths against the provider path
+// policy and render templates at plan time, as the vendored helper/schema package doesn't support
+// CustomizeDiff yet, and to roll back filesystem changes upon failure in transactional mode
+type provider struct {
+	*schema.Provider
+}
+
+var (
+	providerLogFile    = "terraform-provider-filesystem.log"
+	providerJournalDir = "terraform-provider-filesystem.journal"
+)
 
 func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+	p := &provider{&schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"debug": {
 				Type:        schema.TypeBool,
-				Description: fmt.Sprintf("Enable provider debug logging (logs to file %s)", providerLogFile),
+				Description: "Enable provider debug logging (shorthand for log_level = \"debug\")",
+				Optional:    true,
+				Default:     false,
+			},
+			"log_level": {
+				Type:        schema.TypeString,
+				Description: "Provider logging level (error, warning, notice, info or debug, default: logging disabled)",
+				Optional:    true,
+				Default:     "",
+				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+					switch i.(string) {
+					case "", "error", "warning", "notice", "info", "debug":
+					default:
+						errors = append(errors, fmt.Errorf("%q: invalid value", k))
+					}
+					return
+				},
+			},
+			"log_backend": {
+				Type:        schema.TypeString,
+				Description: "Provider logging backend (file, stderr or syslog)",
+				Optional:    true,
+				Default:     "file",
+				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+					switch i.(string) {
+					case "file", "stderr", "syslog":
+					default:
+						errors = append(errors, fmt.Errorf("%q: invalid value", k))
+					}
+					return
+				},
+			},
+			"log_path": {
+				Type:        schema.TypeString,
+				Description: "Provider log file path, when using the file logging backend",
+				Optional:    true,
+				Default:     providerLogFile,
+			},
+			"log_syslog_facility": {
+				Type:        schema.TypeString,
+				Description: "Provider syslog facility, when using the syslog logging backend",
+				Optional:    true,
+				Default:     "user",
+			},
+			"audit_log": {
+				Type:        schema.TypeString,
+				Description: "Path to the JSON lines journal recording every filesystem change (default: disabled)",
+				Optional:    true,
+				Default:     "",
+			},
+			"transactional": {
+				Type:        schema.TypeBool,
+				Description: "Snapshot files before changing them, and roll back the changes of resources failing to apply",
+				Optional:    true,
+				Default:     false,
+			},
+			"journal_dir": {
+				Type:        schema.TypeString,
+				Description: "Directory to store file snapshots into in transactional mode",
+				Optional:    true,
+				Default:     providerJournalDir,
+			},
+			"journal_retention": {
+				Type:        schema.TypeInt,
+				Description: "Number of previous runs kept in the journal directory, older runs being removed when a new run starts",
+				Optional:    true,
+				Default:     10,
+				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+					if i.(int) < 0 {
+						errors = append(errors, fmt.Errorf("%q: must not be negative", k))
+					}
+					return
+				},
+			},
+			"root_dir": {
+				Type:        schema.TypeString,
+				Description: "Directory beneath which all resource paths are resolved (e.g. a container root filesystem)",
+				Optional:    true,
+				Default:     "",
+			},
+			"passwd_file": {
+				Type:          schema.TypeString,
+				Description:   "passwd(5) file to resolve user names from (default: host name service)",
+				Optional:      true,
+				Default:       "",
+				ConflictsWith: []string{"root_dir_accounts"},
+			},
+			"group_file": {
+				Type:          schema.TypeString,
+				Description:   "group(5) file to resolve group names from (default: host name service)",
+				Optional:      true,
+				Default:       "",
+				ConflictsWith: []string{"root_dir_accounts"},
+			},
+			"root_dir_accounts": {
+				Type:        schema.TypeBool,
+				Description: "Resolve user and group names from the root directory /etc/passwd and /etc/group files",
 				Optional:    true,
 				Default:     false,
 			},
+			"allowed_paths": {
+				Type:        schema.TypeList,
+				Description: "Glob patterns of the paths resources are allowed to manage (default: all paths)",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"denied_paths": {
+				Type:        schema.TypeList,
+				Description: "Glob patterns of the paths resources are denied to manage, taking precedence over allowed_paths",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"default_user": {
+				Type:        schema.TypeString,
+				Description: "Default owner user name of files and directories (default: current user)",
+				Optional:    true,
+				Default:     "",
+			},
+			"default_group": {
+				Type:        schema.TypeString,
+				Description: "Default owner group name of files and directories (default: current user group)",
+				Optional:    true,
+				Default:     "",
+			},
+			"default_file_mode": {
+				Type:         schema.TypeString,
+				Description:  "Default permissions to apply to files (in octal representation, e.g. 0644)",
+				Optional:     true,
+				Default:      "0644",
+				ValidateFunc: validateMode,
+			},
+			"default_dir_mode": {
+				Type:         schema.TypeString,
+				Description:  "Default permissions to apply to directories (in octal representation, e.g. 0755)",
+				Optional:     true,
+				Default:      "0755",
+				ValidateFunc: validateMode,
+			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"filesystem_directory": resourceDirectory(),
-			"filesystem_file":      resourceFile(),
+			"filesystem_directory":          resourceDirectory(),
+			"filesystem_env_file":           resourceEnvFile(),
+			"filesystem_file":               resourceFile(),
+			"filesystem_hosts_entry":        resourceHostsEntry(),
+			"filesystem_ini_value":          resourceINIValue(),
+			"filesystem_json_patch":         resourceJSONPatch(),
+			"filesystem_template_directory": resourceTemplateDirectory(),
+			"filesystem_toml_value":         resourceTOMLValue(),
+			"filesystem_xml_value":          resourceXMLValue(),
+			"filesystem_yaml_merge":         resourceYAMLMerge(),
 		},
+	}}
 
-		ConfigureFunc: config,
+	// Filesystem operations are interrupted when Terraform is stopping
+	p.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
+		return config(d, p.StopContext())
 	}
+
+	return p
+}
+
+// Apply implementation of terraform.ResourceProvider interface.
+func (p *provider) Apply(
+	info *terraform.InstanceInfo,
+	s *terraform.InstanceState,
+	d *terraform.InstanceDiff) (*terraform.InstanceState, error) {
+	meta, ok := p.Meta().(filesystemProvider)
+	if !ok || meta.transaction == nil {
+		return p.Provider.Apply(info, s, d)
+	}
+
+	// The changes of each resource are recorded in their own transaction scope, for only the
+	// changes of a failed resource to be rolled back
+	resource, ok := p.ResourcesMap[info.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource type: %s", info.Type)
+	}
+
+	meta.transaction = meta.transaction.scope()
+
+	state, err := resource.Apply(s, d, meta)
+	if err == nil {
+		return state, nil
+	}
+
+	if rollbackErr := meta.transaction.rollback(); rollbackErr != nil {
+		return state, fmt.Errorf("%s (unable to roll back filesystem changes: %s)", err, rollbackErr)
+	}
+
+	meta.log.Warning("rolled back filesystem changes of %s after failure: %s", info.Id, err)
+
+	return state, fmt.Errorf("%s (filesystem changes rolled back)", err)
 }
 
-func config(d *schema.ResourceData) (interface{}, error) {
+// Diff implementation of terraform.ResourceProvider interface.
+func (p *provider) Diff(
+	info *terraform.InstanceInfo,
+	s *terraform.InstanceState,
+	c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+	diff, err := p.Provider.Diff(info, s, c)
+	if err != nil {
+		return diff, err
+	}
+
+	meta, ok := p.Meta().(filesystemProvider)
+	if !ok {
+		return diff, nil
+	}
+
+	if diff == nil {
+		diff = &terraform.InstanceDiff{Attributes: map[string]*terraform.ResourceAttrDiff{}}
+	}
+
+	// Owners and permissions left unset follow the provider defaults
+	if !diff.Destroy {
+		if err := meta.diffDefaults(p.ResourcesMap[info.Type], info.Type, s, c, diff); err != nil {
+			return nil, fmt.Errorf("%s: %s", info.Id, err)
+		}
+	}
+
+	if info.Type == "filesystem_toml_value" && !diff.Destroy {
+		if err := validateTOMLTypedValue(c); err != nil {
+			return nil, fmt.Errorf("%s: %s", info.Id, err)
+		}
+	}
+
+	// Rendered templates may change while the resource attributes don't
+	if info.Type == "filesystem_file" || info.Type == "filesystem_template_directory" {
+		if info.Type == "filesystem_file" {
+			err = diffTemplate(p.ResourcesMap[info.Type], info.Id, s, diff)
+		} else {
+			err = diffTemplateDirectory(meta.ctx, p.ResourcesMap[info.Type], s, diff)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", info.Id, err)
+		}
+	}
+
+	if diff.Empty() {
+		return diff, nil
+	}
+
+	var path string
+	if attrDiff, ok := diff.Attributes["path"]; ok && !attrDiff.NewComputed {
+		path = attrDiff.New
+	} else if s != nil {
+		path = s.Attributes["path"]
+	}
+
+	if path != "" {
+		if err := meta.checkPathPolicy(path); err != nil {
+			return nil, fmt.Errorf("%s: %s", info.Id, err)
+		}
+	}
+
+	if info.Type == "filesystem_file" {
+		if err := meta.diffContentFormat(path, s, diff); err != nil {
+			return nil, fmt.Errorf("%s: %s", info.Id, err)
+		}
+
+		diffContent(s, diff, meta.pathLogger(info.Type, "plan", path))
+		diffTimes(s, c, diff)
+	}
+
+	return diff, nil
+}
+
+func config(d *schema.ResourceData, ctx context.Context) (interface{}, error) {
 	var (
-		p            filesystemProvider
-		loggerConfig logger.FileConfig
-		err          error
+		p             = filesystemProvider{ctx: ctx}
+		loggerConfigs []interface{}
+		err           error
 	)
 
-	if d.Get("debug").(bool) {
-		loggerConfig = logger.FileConfig{
-			Level: "debug",
-			Path:  providerLogFile,
+	logLevel := d.Get("log_level").(string)
+	if logLevel == "" && d.Get("debug").(bool) {
+		logLevel = "debug"
+	}
+
+	// Logging is disabled unless a log level is set (the logger discards messages without backend)
+	if logLevel != "" {
+		switch d.Get("log_backend").(string) {
+		case "file":
+			loggerConfigs = append(loggerConfigs, logger.FileConfig{
+				Level: logLevel,
+				Path:  d.Get("log_path").(string),
+			})
+
+		case "stderr":
+			loggerConfigs = append(loggerConfigs, logger.FileConfig{
+				Level: logLevel,
+				Path:  "-",
+			})
+
+		case "syslog":
+			loggerConfigs = append(loggerConfigs, logger.SyslogConfig{
+				Level:    logLevel,
+				Facility: d.Get("log_syslog_facility").(string),
+				Tag:      "terraform-provider-filesystem",
+			})
+		}
+	}
+
+	if p.log, err = logger.NewLogger(loggerConfigs...); err != nil {
+		return nil, fmt.Errorf("unable to init provider logger: %s", err)
+	}
+
+	if auditLog := d.Get("audit_log").(string); auditLog != "" {
+		if p.audit, err = newAuditJournal(auditLog); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Get("transactional").(bool) {
+		transaction, err := newTransaction(d.Get("journal_dir").(string), d.Get("journal_retention").(int))
+		if err != nil {
+			return nil, err
 		}
+		p.transaction = transaction.scope()
 	}
 
-	if p.log, err = logger.NewLogger(loggerConfig); err != nil {
-		return nil, fmt.Errorf("unable to init provider debug logger: %s", err)
+	if rootDir := d.Get("root_dir").(string); rootDir != "" {
+		if p.rootDir, err = filepath.Abs(rootDir); err != nil {
+			return nil, fmt.Errorf("unable to resolve root directory: %s", err)
+		}
+
+		if p.rootDir, err = filepath.EvalSymlinks(p.rootDir); err != nil {
+			return nil, fmt.Errorf("unable to resolve root directory: %s", err)
+		}
 	}
 
+	for _, rule := range d.Get("allowed_paths").([]interface{}) {
+		p.allowedPaths = append(p.allowedPaths, rule.(string))
+	}
+	if err := validatePathRules("allowed_paths", p.allowedPaths); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range d.Get("denied_paths").([]interface{}) {
+		p.deniedPaths = append(p.deniedPaths, rule.(string))
+	}
+	if err := validatePathRules("denied_paths", p.deniedPaths); err != nil {
+		return nil, err
+	}
+
+	p.accounts = accountDatabase{
+		passwdFile: d.Get("passwd_file").(string),
+		groupFile:  d.Get("group_file").(string),
+	}
+
+	if d.Get("root_dir_accounts").(bool) {
+		if p.rootDir == "" {
+			return nil, fmt.Errorf("root_dir_accounts requires root_dir to be set")
+		}
+
+		if p.accounts.passwdFile, err = p.resolveRootPath("/etc/passwd"); err != nil {
+			return nil, err
+		}
+
+		if p.accounts.groupFile, err = p.resolveRootPath("/etc/group"); err != nil {
+			return nil, err
+		}
+	}
+
+	p.defaultUser = d.Get("default_user").(string)
+	p.defaultGroup = d.Get("default_group").(string)
+
+	fileMode, _ := strconv.ParseUint(d.Get("default_file_mode").(string), 8, 32)
+	p.defaultFileMode = os.FileMode(fileMode)
+
+	dirMode, _ := strconv.ParseUint(d.Get("default_dir_mode").(string), 8, 32)
+	p.defaultDirMode = os.FileMode(dirMode)
+
 	return p, nil
 }
 
-func getCurrentUsername() (interface{}, error) {
-	currentUser, err := user.Current()
+// resourceLogger returns a logger annotating messages with the resource type, path and operation
+func (p filesystemProvider) resourceLogger(resourceType, operation string, d *schema.ResourceData) *logger.Logger {
+	return p.pathLogger(resourceType, operation, d.Get("path").(string))
+}
+
+func (p filesystemProvider) pathLogger(resourceType, operation, path string) *logger.Logger {
+	return p.log.Context(fmt.Sprintf("resource=%s path=%q operation=%s", resourceType, path, operation))
+}
+
+// defaultOwner returns the provider default user and group, or the current user and group
+func (p filesystemProvider) defaultOwner() (string, string, error) {
+	username, groupname := p.defaultUser, p.defaultGroup
+
+	var err error
+	if username == "" {
+		if username, err = p.accounts.currentUsername(); err != nil {
+			return "", "", err
+		}
+	}
+
+	if groupname == "" {
+		if groupname, err = p.accounts.currentUserGroupname(); err != nil {
+			return "", "", err
+		}
+	}
+
+	return username, groupname, nil
+}
+
+// setDefaultOwner sets the `user` and `group` attributes left unset to the provider default user
+// and group, or to the current user and group
+func (p filesystemProvider) setDefaultOwner(d *schema.ResourceData) error {
+	if d.Get("user").(string) != "" && d.Get("group").(string) != "" {
+		return nil
+	}
+
+	username, groupname, err := p.defaultOwner()
 	if err != nil {
-		return "", fmt.Errorf("unable to lookup current user name: %s", err)
+		return err
+	}
+
+	if d.Get("user").(string) == "" {
+		d.Set("user", username)
 	}
-	return currentUser.Username, nil
+
+	if d.Get("group").(string) == "" {
+		d.Set("group", groupname)
+	}
+
+	return nil
 }
 
-func getCurrentUserGroupname() (interface{}, error) {
-	currentUser, err := user.Current()
+// defaultAttributes returns the provider defaults of the owner and permissions attributes of a
+// resource type, by attribute name
+func (p filesystemProvider) defaultAttributes(resourceType string, c *terraform.ResourceConfig) (map[string]string, error) {
+	var defaults map[string]string
+
+	switch resourceType {
+	case "filesystem_directory":
+		defaults = map[string]string{"mode": fmt.Sprintf("%#o", p.defaultDirMode)}
+	case "filesystem_env_file":
+		defaults = map[string]string{"mode": fmt.Sprintf("%#o", p.defaultFileMode)}
+	case "filesystem_file":
+		defaults = map[string]string{"mode": fmt.Sprintf("%#o", p.defaultFileMode)}
+		if _, ok := c.Get("sensitive_content"); ok {
+			defaults["mode"] = "0600"
+		}
+	case "filesystem_template_directory":
+		defaults = map[string]string{"file_mode": fmt.Sprintf("%#o", p.defaultFileMode), "dir_mode": fmt.Sprintf("%#o", p.defaultDirMode)}
+	default:
+		return nil, nil
+	}
+
+	username, groupname, err := p.defaultOwner()
 	if err != nil {
-		return "", fmt.Errorf("unable to lookup current user name: %s", err)
+		return nil, err
 	}
+	defaults["user"], defaults["group"] = username, groupname
 
-	currentGroup, err := user.LookupGroupId(currentUser.Gid)
+	return defaults, nil
+}
+
+// diffDefaults compares the owner and permissions attributes left unset in the configuration with
+// the provider defaults: as they are computed (the defaults being only known once the provider is
+// configured), they would otherwise never be reported as changed
+func (p filesystemProvider) diffDefaults(resource *schema.Resource, resourceType string, s *terraform.InstanceState, c *terraform.ResourceConfig, diff *terraform.InstanceDiff) error {
+	defaults, err := p.defaultAttributes(resourceType, c)
 	if err != nil {
-		return "", fmt.Errorf("unable to lookup current user group name: %s", err)
+		return err
 	}
 
-	return currentGroup.Name, nil
+	for attribute, value := range defaults {
+		if _, ok := c.Get(attribute); ok {
+			continue
+		}
+
+		stateValue := value
+		if stateFunc := resource.Schema[attribute].StateFunc; stateFunc != nil {
+			stateValue = stateFunc(value)
+		}
+
+		var old string
+		if s != nil && !diff.RequiresNew() {
+			old = s.Attributes[attribute]
+		}
+
+		if old == stateValue {
+			delete(diff.Attributes, attribute)
+			continue
+		}
+		diff.Attributes[attribute] = &terraform.ResourceAttrDiff{Old: old, New: stateValue, NewExtra: value}
+	}
+
+	return nil
+}
+
+func validateMode(i interface{}, k string) (ws []string, errors []error) {
+	if _, err := strconv.ParseUint(i.(string), 8, 32); err != nil {
+		errors = append(errors, fmt.Errorf("%q: invalid value", k))
+	}
+	return
+}
+
+// contentDigestPrefix identifies the digest algorithm of content attributes stored in state
+const contentDigestPrefix = "sha256:"
+
+// contentDigest returns the self-describing digest of content stored in state instead of content
+func contentDigest(content string) string {
+	return contentDigestPrefix + hash(content)
 }
 
 func hash(s string) string {
@@ -3,7 +3,6 @@ package filesystem
 import (
 	"fmt"
 	"os"
-	"os/user"
 	"strconv"
 	"syscall"
 
@@ -12,7 +11,7 @@ import (
 
 func resourceDirectory() *schema.Resource {
 	return &schema.Resource{
-		Schema: map[string]*schema.Schema{
+		Schema: withCommandHooks(map[string]*schema.Schema{
 			"path": {
 				Type:        schema.TypeString,
 				Description: "Path to the directory to be created",
@@ -21,30 +20,25 @@ func resourceDirectory() *schema.Resource {
 			},
 			"user": {
 				Type:        schema.TypeString,
-				Description: "Directory owner user name (default: current user)",
+				Description: "Directory owner user name (default: provider default_user or current user)",
 				Optional:    true,
+				Computed:    true,
 				ForceNew:    false,
-				DefaultFunc: getCurrentUsername,
 			},
 			"group": {
 				Type:        schema.TypeString,
-				Description: "Directory owner group name (default: current user group)",
+				Description: "Directory owner group name (default: provider default_group or current user group)",
 				Optional:    true,
+				Computed:    true,
 				ForceNew:    false,
-				DefaultFunc: getCurrentUserGroupname,
 			},
 			"mode": {
-				Type:        schema.TypeString,
-				Description: "Permissions to apply to directory (in octal representation, e.g. 0755)",
-				Optional:    true,
-				Default:     "0755",
-				ForceNew:    false,
-				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
-					if _, err := strconv.ParseUint(i.(string), 8, 32); err != nil {
-						errors = append(errors, fmt.Errorf("%q: invalid value", k))
-					}
-					return
-				},
+				Type:         schema.TypeString,
+				Description:  "Permissions to apply to directory (in octal representation, e.g. 0755)",
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     false,
+				ValidateFunc: validateMode,
 				StateFunc: func(v interface{}) string {
 					// We serialize the permissions including 'directory mode' (e.g. `020000000755`) or else
 					// the internal format will always be found different from the state format (`0755`)
@@ -52,6 +46,24 @@ func resourceDirectory() *schema.Resource {
 					return fmt.Sprintf("%#o", os.ModeDir|os.FileMode(permBits))
 				},
 			},
+			"mtime": {
+				Type:             schema.TypeString,
+				Description:      "Directory modification time (in RFC3339 format, e.g. 2018-02-23T00:00:00Z)",
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         false,
+				ValidateFunc:     validateTimestamp,
+				DiffSuppressFunc: suppressEquivalentTimestamps,
+			},
+			"atime": {
+				Type:             schema.TypeString,
+				Description:      "Directory access time (in RFC3339 format, e.g. 2018-02-23T00:00:00Z)",
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         false,
+				ValidateFunc:     validateTimestamp,
+				DiffSuppressFunc: suppressEquivalentTimestamps,
+			},
 			"create_parents": {
 				Type:        schema.TypeBool,
 				Description: "Create parent directories as needed",
@@ -59,7 +71,12 @@ func resourceDirectory() *schema.Resource {
 				Default:     false,
 				ForceNew:    false,
 			},
-		},
+		}),
+
+		SchemaVersion: len(directoryStateMigrations),
+		MigrateState:  migrateState(directoryStateMigrations),
+
+		Timeouts: resourceTimeouts(),
 
 		Create: resourceFilesystemDirectoryCreate,
 		Read:   resourceFilesystemDirectoryRead,
@@ -69,61 +86,74 @@ func resourceDirectory() *schema.Resource {
 }
 
 func resourceFilesystemDirectoryCreate(d *schema.ResourceData, meta interface{}) error {
-	p := meta.(filesystemProvider)
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
 
-	p.log.Debug("calling resourceFilesystemDirectoryCreate()")
+	log := p.resourceLogger("filesystem_directory", "create", d)
+	log.Debug("calling resourceFilesystemDirectoryCreate()")
 
-	dirMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
 
-	if d.Get("create_parents").(bool) {
-		if err := os.MkdirAll(d.Get("path").(string), os.FileMode(dirMode)); err != nil {
-			return err
-		}
-	} else {
-		if err := os.Mkdir(d.Get("path").(string), os.FileMode(dirMode)); err != nil {
-			return err
-		}
+	if d.Get("mode").(string) == "" {
+		d.Set("mode", fmt.Sprintf("%#o", p.defaultDirMode))
 	}
 
-	dir, err := os.OpenFile(d.Get("path").(string), os.O_RDONLY, os.FileMode(dirMode))
-	if err != nil {
+	dirMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
+
+	if err := p.mkdir(path, os.FileMode(dirMode), d.Get("create_parents").(bool)); err != nil {
 		return err
 	}
-	defer dir.Close()
 
-	dirInfo, err := dir.Stat()
+	dirInfo, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
 	d.Set("mode", fmt.Sprintf("%#o", dirInfo.Mode()))
 
-	u, err := user.Lookup(d.Get("user").(string))
+	if err := p.setDefaultOwner(d); err != nil {
+		return err
+	}
+
+	uid, err := p.accounts.lookupUser(d.Get("user").(string))
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner user information: %s", err)
 	}
-	uid, _ := strconv.Atoi(u.Uid)
 
-	g, err := user.LookupGroup(d.Get("group").(string))
+	gid, err := p.accounts.lookupGroup(d.Get("group").(string))
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner group information: %s", err)
 	}
-	gid, _ := strconv.Atoi(g.Gid)
 
-	if err := dir.Chown(uid, gid); err != nil {
-		return fmt.Errorf("unable to change file user/group: %s", err)
+	if err := p.chown(path, uid, gid); err != nil {
+		return fmt.Errorf("unable to change directory user/group: %s", err)
 	}
 
-	d.SetId(hash(dir.Name()))
+	if err := p.setTimes(d, path); err != nil {
+		return err
+	}
 
-	return nil
+	d.SetId(d.Get("path").(string))
+
+	log.Info("created directory (mode %s, owner %s:%s)", d.Get("mode"), d.Get("user"), d.Get("group"))
+
+	return p.runHook(d, "create", path, "", "", log)
 }
 
 func resourceFilesystemDirectoryRead(d *schema.ResourceData, meta interface{}) error {
 	p := meta.(filesystemProvider)
 
-	p.log.Debug("calling resourceFilesystemDirectoryRead()")
+	log := p.resourceLogger("filesystem_directory", "read", d)
+	log.Debug("calling resourceFilesystemDirectoryRead()")
 
-	dirInfo, err := os.Stat(d.Get("path").(string))
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	dirInfo, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			d.SetId("")
@@ -133,66 +163,95 @@ func resourceFilesystemDirectoryRead(d *schema.ResourceData, meta interface{}) e
 		return err
 	}
 	d.Set("mode", fmt.Sprintf("%#o", dirInfo.Mode()))
+	readTimes(d, dirInfo)
 
-	u, err := user.LookupId(fmt.Sprintf("%d", dirInfo.Sys().(*syscall.Stat_t).Uid))
+	username, err := p.accounts.lookupUserID(int(dirInfo.Sys().(*syscall.Stat_t).Uid))
 	if err != nil {
 		return fmt.Errorf("unable to lookup directory owner user information: %s", err)
 	}
-	d.Set("user", u.Username)
+	d.Set("user", username)
 
-	g, err := user.LookupGroupId(fmt.Sprintf("%d", dirInfo.Sys().(*syscall.Stat_t).Gid))
+	groupname, err := p.accounts.lookupGroupID(int(dirInfo.Sys().(*syscall.Stat_t).Gid))
 	if err != nil {
 		return fmt.Errorf("unable to lookup directory owner group information: %s", err)
 	}
-	d.Set("group", g.Name)
+	d.Set("group", groupname)
 
 	return nil
 }
 
 func resourceFilesystemDirectoryUpdate(d *schema.ResourceData, meta interface{}) error {
-	p := meta.(filesystemProvider)
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
 
-	p.log.Debug("calling resourceFilesystemDirectoryUpdate()")
+	log := p.resourceLogger("filesystem_directory", "update", d)
+	log.Debug("calling resourceFilesystemDirectoryUpdate()")
 
-	dir, err := os.OpenFile(d.Get("path").(string), os.O_RDONLY, 0666)
+	path, err := p.resolvePath(d.Get("path").(string))
 	if err != nil {
 		return err
 	}
-	defer dir.Close()
 
 	if d.HasChange("mode") {
 		dirMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
 
-		if err := dir.Chmod(os.FileMode(dirMode)); err != nil {
+		if err := p.chmod(path, os.FileMode(dirMode)); err != nil {
 			return err
 		}
+
+		log.Info("changed mode to %s", d.Get("mode"))
 	}
 
 	if d.HasChange("user") || d.HasChange("group") {
-		u, err := user.Lookup(d.Get("user").(string))
+		uid, err := p.accounts.lookupUser(d.Get("user").(string))
 		if err != nil {
 			return fmt.Errorf("unable to lookup directory owner user information: %s", err)
 		}
-		uid, _ := strconv.Atoi(u.Uid)
 
-		g, err := user.LookupGroup(d.Get("group").(string))
+		gid, err := p.accounts.lookupGroup(d.Get("group").(string))
 		if err != nil {
 			return fmt.Errorf("unable to lookup directory owner group information: %s", err)
 		}
-		gid, _ := strconv.Atoi(g.Gid)
 
-		if err := dir.Chown(uid, gid); err != nil {
+		if err := p.chown(path, uid, gid); err != nil {
 			return fmt.Errorf("unable to change directory user/group: %s", err)
 		}
+
+		log.Info("changed owner to %s:%s", d.Get("user"), d.Get("group"))
+	}
+
+	if d.HasChange("mtime") || d.HasChange("atime") {
+		if err := p.setTimes(d, path); err != nil {
+			return err
+		}
+
+		log.Info("changed access/modification times")
+	}
+
+	if d.HasChange("mode") || d.HasChange("user") || d.HasChange("group") {
+		return p.runHook(d, "change", path, "", "", log)
 	}
 
 	return nil
 }
 
 func resourceFilesystemDirectoryDelete(d *schema.ResourceData, meta interface{}) error {
-	p := meta.(filesystemProvider)
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_directory", "delete", d)
+	log.Debug("calling resourceFilesystemDirectoryDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := p.remove(path); err != nil {
+		return err
+	}
 
-	p.log.Debug("calling resourceFilesystemDirectoryDelete()")
+	log.Info("removed directory")
 
-	return os.Remove(d.Get("path").(string))
+	return p.runHook(d, "destroy", path, "", "", log)
 }
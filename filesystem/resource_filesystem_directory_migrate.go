@@ -0,0 +1,5 @@
+package filesystem
+
+var directoryStateMigrations = []stateMigration{
+	migrateStateV0toV1,
+}
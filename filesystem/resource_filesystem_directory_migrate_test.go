@@ -0,0 +1,55 @@
+package filesystem
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestFilesystemDirectoryMigrateState(t *testing.T) {
+	cases := map[string]struct {
+		StateVersion int
+		ID           string
+		Attributes   map[string]string
+		Expected     *terraform.InstanceState
+	}{
+		"v0_1": {
+			StateVersion: 0,
+			ID:           hash("/tmp/testdir"),
+			Attributes: map[string]string{
+				"path":           "/tmp/testdir",
+				"user":           "root",
+				"group":          "root",
+				"mode":           "020000000755",
+				"create_parents": "false",
+			},
+			Expected: &terraform.InstanceState{
+				ID: "/tmp/testdir",
+				Attributes: map[string]string{
+					"path":           "/tmp/testdir",
+					"user":           "root",
+					"group":          "root",
+					"mode":           "020000000755",
+					"create_parents": "false",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		is := &terraform.InstanceState{
+			ID:         tc.ID,
+			Attributes: tc.Attributes,
+		}
+
+		is, err := resourceDirectory().MigrateState(tc.StateVersion, is, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+
+		if !reflect.DeepEqual(is, tc.Expected) {
+			t.Fatalf("%s: migrated state (%#v) different from expected state (%#v)", name, is, tc.Expected)
+		}
+	}
+}
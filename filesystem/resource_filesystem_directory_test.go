@@ -72,7 +72,7 @@ func testFilesystemDirectoryCreateParents(state *terraform.State) error {
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner user information: %s", err)
 	}
-	currentUsername, _ := getCurrentUsername()
+	currentUsername, _ := accountDatabase{}.currentUsername()
 	if u.Username != currentUsername {
 		return fmt.Errorf("test directory username (%q) different from expected username (%q)",
 			u.Username,
@@ -83,7 +83,7 @@ func testFilesystemDirectoryCreateParents(state *terraform.State) error {
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner group information: %s", err)
 	}
-	currentUserGroupname, _ := getCurrentUserGroupname()
+	currentUserGroupname, _ := accountDatabase{}.currentUserGroupname()
 	if g.Name != currentUserGroupname {
 		return fmt.Errorf("test directory groupname (%q) different from expected groupname (%q)",
 			g.Name,
@@ -135,3 +135,63 @@ func testFilesystemDirectoryDelete(state *terraform.State) error {
 
 	return fmt.Errorf("test directory not deleted properly")
 }
+
+func TestAccFilesystemDirectoryProviderDefaults(t *testing.T) {
+	const (
+		directoryCreateProviderDefaultsResource = `
+provider "filesystem" {
+  default_user = "daemon"
+  default_group = "daemon"
+  default_dir_mode = "0750"
+}
+
+resource "filesystem_directory" "test" {
+  path = "/tmp/testdir"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemDirectoryProviderDefaults,
+					resource.TestCheckResourceAttr("filesystem_directory.test", "user", "daemon"),
+					resource.TestCheckResourceAttr("filesystem_directory.test", "group", "daemon"),
+					resource.TestCheckResourceAttr("filesystem_directory.test", "mode", fmt.Sprintf("%#o", os.ModeDir|0750)),
+				),
+				Config: directoryCreateProviderDefaultsResource,
+			},
+		},
+		CheckDestroy: testFilesystemDirectoryDelete,
+	})
+}
+
+func testFilesystemDirectoryProviderDefaults(state *terraform.State) error {
+	rs, ok := state.RootModule().Resources["filesystem_directory.test"]
+	if !ok {
+		return fmt.Errorf("Not found: %s", "filesystem_directory.test")
+	}
+
+	fileInfo, err := os.Stat(rs.Primary.Attributes["path"])
+	if err != nil {
+		return err
+	}
+
+	u, err := user.LookupId(fmt.Sprintf("%d", fileInfo.Sys().(*syscall.Stat_t).Uid))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner user information: %s", err)
+	}
+	if u.Username != "daemon" {
+		return fmt.Errorf("test directory username (%q) different from expected username (%q)", u.Username, "daemon")
+	}
+
+	if fileInfo.Mode() != os.ModeDir|os.FileMode(0750) {
+		return fmt.Errorf("test directory mode (%#o) different from expected mode (%#o)",
+			fileInfo.Mode(),
+			os.ModeDir|0750)
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceEnvFile() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the environment file to be created",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"variables": {
+				Type:         schema.TypeMap,
+				Description:  "Variables to define, by name",
+				Required:     true,
+				ForceNew:     false,
+				ValidateFunc: validateEnvVariables,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+			},
+			"dialect": {
+				Type:        schema.TypeString,
+				Description: "Dialect of the file, defining how values are quoted (shell, systemd or docker-compose)",
+				Optional:    true,
+				Default:     "shell",
+				ForceNew:    false,
+				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+					for _, dialect := range envDialects {
+						if i.(string) == dialect {
+							return
+						}
+					}
+					errors = append(errors, fmt.Errorf("%q: must be one of %s", k, strings.Join(envDialects, ", ")))
+					return
+				},
+			},
+			"user": {
+				Type:        schema.TypeString,
+				Description: "File owner user name (default: provider default_user or current user)",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    false,
+			},
+			"group": {
+				Type:        schema.TypeString,
+				Description: "File owner group name (default: provider default_group or current user group)",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    false,
+			},
+			"mode": {
+				Type:         schema.TypeString,
+				Description:  "Permissions to apply to file (in octal representation, e.g. 0644)",
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     false,
+				ValidateFunc: validateMode,
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemEnvFileCreate,
+		Read:   resourceFilesystemEnvFileRead,
+		Update: resourceFilesystemEnvFileUpdate,
+		Delete: resourceFilesystemEnvFileDelete,
+	}
+}
+
+func resourceFilesystemEnvFileCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_env_file", "create", d)
+	log.Debug("calling resourceFilesystemEnvFileCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if d.Get("mode").(string) == "" {
+		d.Set("mode", fmt.Sprintf("%#o", p.defaultFileMode))
+	}
+
+	fileMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
+	d.Set("mode", fmt.Sprintf("%#o", os.FileMode(fileMode)))
+
+	content := formatEnvFile(d.Get("variables").(map[string]interface{}), d.Get("dialect").(string))
+	if err := p.writeFile(path, []byte(content), os.FileMode(fileMode), nil); err != nil {
+		return err
+	}
+
+	if err := p.setDefaultOwner(d); err != nil {
+		return err
+	}
+
+	if err := p.chownEnvFile(d, path); err != nil {
+		return err
+	}
+
+	d.SetId(d.Get("path").(string))
+
+	log.Info("created environment file (%d variables, mode %s, owner %s:%s)", len(d.Get("variables").(map[string]interface{})), d.Get("mode"), d.Get("user"), d.Get("group"))
+
+	return nil
+}
+
+func resourceFilesystemEnvFileRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_env_file", "read", d)
+	log.Debug("calling resourceFilesystemEnvFileRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+	d.Set("mode", fmt.Sprintf("%#o", fileInfo.Mode()))
+
+	content, err := p.readFile(path)
+	if err != nil {
+		return err
+	}
+
+	// The variables are read back, for drifts to be reported by variable
+	d.Set("variables", parseEnvFile(content, d.Get("dialect").(string)))
+
+	username, err := p.accounts.lookupUserID(int(fileInfo.Sys().(*syscall.Stat_t).Uid))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner user information: %s", err)
+	}
+	d.Set("user", username)
+
+	groupname, err := p.accounts.lookupGroupID(int(fileInfo.Sys().(*syscall.Stat_t).Gid))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner group information: %s", err)
+	}
+	d.Set("group", groupname)
+
+	return nil
+}
+
+func resourceFilesystemEnvFileUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_env_file", "update", d)
+	log.Debug("calling resourceFilesystemEnvFileUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	fileMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
+
+	if d.HasChange("mode") {
+		if err := p.chmod(path, os.FileMode(fileMode)); err != nil {
+			return err
+		}
+
+		log.Info("changed mode to %s", d.Get("mode"))
+	}
+
+	if d.HasChange("user") || d.HasChange("group") {
+		if err := p.chownEnvFile(d, path); err != nil {
+			return err
+		}
+
+		log.Info("changed owner to %s:%s", d.Get("user"), d.Get("group"))
+	}
+
+	if d.HasChange("variables") || d.HasChange("dialect") {
+		content := formatEnvFile(d.Get("variables").(map[string]interface{}), d.Get("dialect").(string))
+		if err := p.writeFile(path, []byte(content), os.FileMode(fileMode), nil); err != nil {
+			return err
+		}
+
+		log.Info("updated variables (%d variables)", len(d.Get("variables").(map[string]interface{})))
+	}
+
+	return nil
+}
+
+func resourceFilesystemEnvFileDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_env_file", "delete", d)
+	log.Debug("calling resourceFilesystemEnvFileDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := p.remove(path); err != nil {
+		return err
+	}
+
+	log.Info("removed environment file")
+
+	return nil
+}
+
+// chownEnvFile applies the owner of a filesystem_env_file resource
+func (p filesystemProvider) chownEnvFile(d *schema.ResourceData, path string) error {
+	uid, err := p.accounts.lookupUser(d.Get("user").(string))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner user information: %s", err)
+	}
+
+	gid, err := p.accounts.lookupGroup(d.Get("group").(string))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner group information: %s", err)
+	}
+
+	if err := p.chown(path, uid, gid); err != nil {
+		return fmt.Errorf("unable to change file user/group: %s", err)
+	}
+
+	return nil
+}
+
+func validateEnvVariables(i interface{}, k string) (ws []string, errors []error) {
+	for name := range i.(map[string]interface{}) {
+		if !envKeyRegexp.MatchString(name) {
+			errors = append(errors, fmt.Errorf("%q: invalid variable name %q", k, name))
+		}
+	}
+	return
+}
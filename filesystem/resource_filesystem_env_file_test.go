@@ -0,0 +1,90 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccFilesystemEnvFile(t *testing.T) {
+	const (
+		envFileCreateResource = `
+resource "filesystem_env_file" "test" {
+  path = "/tmp/testfile.env"
+  dialect = "systemd"
+  mode = "0600"
+
+  variables = {
+    OPTS = "-Xmx1g -Dname=\"app\""
+    HOME = "/var/lib/app"
+  }
+}
+`
+
+		envFileUpdateResource = `
+resource "filesystem_env_file" "test" {
+  path = "/tmp/testfile.env"
+  dialect = "docker-compose"
+  mode = "0600"
+
+  variables = {
+    OPTS = "-Xmx1g -Dname=\"app\""
+    PASSWORD = "pa$$word"
+  }
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemEnvFileContent("HOME=/var/lib/app\nOPTS=\"-Xmx1g -Dname=\\\"app\\\"\"\n"),
+					resource.TestCheckResourceAttr("filesystem_env_file.test", "mode", "0600"),
+				),
+				Config: envFileCreateResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemEnvFileContent("OPTS='-Xmx1g -Dname=\"app\"'\nPASSWORD='pa$word'\n")),
+				Config: envFileUpdateResource,
+			},
+			resource.TestStep{
+				// Variables changed outside of Terraform are set again
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.env", []byte("# edited\nOPTS='-Xmx1g -Dname=\"app\"'\nPASSWORD=changed\n"), 0600)
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemEnvFileContent("OPTS='-Xmx1g -Dname=\"app\"'\nPASSWORD='pa$word'\n")),
+				Config: envFileUpdateResource,
+			},
+		},
+		CheckDestroy: testFilesystemEnvFileDelete,
+	})
+}
+
+func testFilesystemEnvFileContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.env")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
+
+func testFilesystemEnvFileDelete(state *terraform.State) error {
+	if _, err := os.Stat("/tmp/testfile.env"); !os.IsNotExist(err) {
+		return fmt.Errorf("test file not removed")
+	}
+
+	return nil
+}
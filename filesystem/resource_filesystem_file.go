@@ -2,9 +2,7 @@ package filesystem
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/user"
 	"strconv"
 	"syscall"
 
@@ -13,7 +11,7 @@ import (
 
 func resourceFile() *schema.Resource {
 	return &schema.Resource{
-		Schema: map[string]*schema.Schema{
+		Schema: withCommandHooks(map[string]*schema.Schema{
 			"path": {
 				Type:        schema.TypeString,
 				Description: "Path to the file to be created",
@@ -22,42 +20,149 @@ func resourceFile() *schema.Resource {
 			},
 			"user": {
 				Type:        schema.TypeString,
-				Description: "File owner user name (default: current user)",
+				Description: "File owner user name (default: provider default_user or current user)",
 				Optional:    true,
+				Computed:    true,
 				ForceNew:    false,
-				DefaultFunc: getCurrentUsername,
 			},
 			"group": {
 				Type:        schema.TypeString,
-				Description: "File owner group name (default: current user group)",
+				Description: "File owner group name (default: provider default_group or current user group)",
 				Optional:    true,
+				Computed:    true,
 				ForceNew:    false,
-				DefaultFunc: getCurrentUserGroupname,
 			},
 			"mode": {
+				Type:         schema.TypeString,
+				Description:  "Permissions to apply to file (in octal representation, e.g. 0644)",
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     false,
+				ValidateFunc: validateMode,
+			},
+			"mtime": {
+				Type:             schema.TypeString,
+				Description:      "File modification time (in RFC3339 format, e.g. 2018-02-23T00:00:00Z)",
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         false,
+				ValidateFunc:     validateTimestamp,
+				DiffSuppressFunc: suppressEquivalentTimestamps,
+			},
+			"atime": {
+				Type:             schema.TypeString,
+				Description:      "File access time (in RFC3339 format, e.g. 2018-02-23T00:00:00Z)",
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         false,
+				ValidateFunc:     validateTimestamp,
+				DiffSuppressFunc: suppressEquivalentTimestamps,
+			},
+			"content": {
 				Type:        schema.TypeString,
-				Description: "Permissions to apply to file (in octal representation, e.g. 0644)",
+				Description: "File content",
+				Optional:    true,
+				Default:     "",
+				ForceNew:    false,
+				StateFunc: func(v interface{}) string {
+					return contentDigest(v.(string))
+				},
+			},
+			"sensitive_content": {
+				Type:          schema.TypeString,
+				Description:   "Sensitive file content, never shown in plans nor logged (default mode: 0600)",
+				Optional:      true,
+				Sensitive:     true,
+				ForceNew:      false,
+				ConflictsWith: []string{"content"},
+				StateFunc: func(v interface{}) string {
+					return contentDigest(v.(string))
+				},
+			},
+			"content_json": {
+				Type:          schema.TypeString,
+				Description:   "JSON file content, written in canonical form (sorted keys, two spaces indentation)",
+				Optional:      true,
+				ForceNew:      false,
+				ConflictsWith: []string{"content", "sensitive_content", "content_yaml", "template", "template_source"},
+				ValidateFunc:  validateJSON,
+				StateFunc: func(v interface{}) string {
+					canonical, _ := canonicalJSON(v.(string))
+					return contentDigest(canonical)
+				},
+			},
+			"content_yaml": {
+				Type:          schema.TypeString,
+				Description:   "YAML file content, written in canonical form (sorted keys, two spaces indentation)",
+				Optional:      true,
+				ForceNew:      false,
+				ConflictsWith: []string{"content", "sensitive_content", "template", "template_source"},
+				ValidateFunc:  validateYAML,
+				StateFunc: func(v interface{}) string {
+					canonical, _ := canonicalYAML(v.(string))
+					return contentDigest(canonical)
+				},
+			},
+			"template": {
+				Type:          schema.TypeString,
+				Description:   "Go text/template rendered with vars into the file content at plan time",
+				Optional:      true,
+				ForceNew:      false,
+				ConflictsWith: []string{"content", "sensitive_content", "template_source"},
+			},
+			"template_source": {
+				Type:          schema.TypeString,
+				Description:   "Path to a Go text/template file rendered with vars into the file content at plan time",
+				Optional:      true,
+				ForceNew:      false,
+				ConflictsWith: []string{"content", "sensitive_content"},
+			},
+			"vars": {
+				Type:        schema.TypeMap,
+				Description: "Variables to render the template with",
+				Optional:    true,
+				ForceNew:    false,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"validate_format": {
+				Type:        schema.TypeString,
+				Description: "Format the file content must be well-formed in at plan time (json, yaml, toml, ini or xml)",
 				Optional:    true,
-				Default:     "0644",
 				ForceNew:    false,
 				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
-					if _, err := strconv.ParseUint(i.(string), 8, 32); err != nil {
+					switch i.(string) {
+					case "", "json", "yaml", "toml", "ini", "xml":
+					default:
 						errors = append(errors, fmt.Errorf("%q: invalid value", k))
 					}
 					return
 				},
 			},
-			"content": {
-				Type:        schema.TypeString,
-				Description: "File content",
+			"validate_command": {
+				Type:         schema.TypeString,
+				Description:  "Command validating the new file content before it is installed, %s being replaced by the path of a temporary file holding it (e.g. visudo -cf %s)",
+				Optional:     true,
+				ForceNew:     false,
+				ValidateFunc: validateValidateCommand,
+			},
+			"store_content_in_state": {
+				Type:        schema.TypeBool,
+				Description: "Store file content in state instead of its digest, for plans to show content changes",
 				Optional:    true,
-				Default:     "",
+				Default:     false,
 				ForceNew:    false,
-				StateFunc: func(v interface{}) string {
-					return hash(v.(string))
-				},
 			},
-		},
+			"content_diff": {
+				Type:        schema.TypeString,
+				Description: "Line changes of the planned content update, when content is stored in state",
+				Computed:    true,
+			},
+		}),
+
+		SchemaVersion: len(fileStateMigrations),
+		MigrateState:  migrateState(fileStateMigrations),
+
+		Timeouts: resourceTimeouts(),
 
 		Create: resourceFilesystemFileCreate,
 		Read:   resourceFilesystemFileRead,
@@ -67,50 +172,87 @@ func resourceFile() *schema.Resource {
 }
 
 func resourceFilesystemFileCreate(d *schema.ResourceData, meta interface{}) error {
-	p := meta.(filesystemProvider)
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
 
-	p.log.Debug("calling resourceFilesystemFileCreate()")
+	log := p.resourceLogger("filesystem_file", "create", d)
+	log.Debug("calling resourceFilesystemFileCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if d.Get("mode").(string) == "" {
+		if _, ok := d.GetOk("sensitive_content"); ok {
+			d.Set("mode", "0600")
+		} else {
+			d.Set("mode", fmt.Sprintf("%#o", p.defaultFileMode))
+		}
+	}
 
 	fileMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
 	d.Set("mode", fmt.Sprintf("%#o", os.FileMode(fileMode)))
 
-	file, err := os.OpenFile(d.Get("path").(string), os.O_RDWR|os.O_CREATE, os.FileMode(fileMode))
+	content, err := fileContent(d)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	if _, err := file.WriteString(d.Get("content").(string)); err != nil {
+	if err := checkContentFormat(contentAttribute(d), d.Get("validate_format").(string), content); err != nil {
+		return err
+	}
+
+	if err := p.writeFile(path, []byte(content), os.FileMode(fileMode), p.contentValidator(d.Get("validate_command").(string))); err != nil {
+		return err
+	}
+
+	if err := p.setDefaultOwner(d); err != nil {
 		return err
 	}
 
-	u, err := user.Lookup(d.Get("user").(string))
+	uid, err := p.accounts.lookupUser(d.Get("user").(string))
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner user information: %s", err)
 	}
-	uid, _ := strconv.Atoi(u.Uid)
 
-	g, err := user.LookupGroup(d.Get("group").(string))
+	gid, err := p.accounts.lookupGroup(d.Get("group").(string))
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner group information: %s", err)
 	}
-	gid, _ := strconv.Atoi(g.Gid)
 
-	if err := file.Chown(uid, gid); err != nil {
+	if err := p.chown(path, uid, gid); err != nil {
 		return fmt.Errorf("unable to change file user/group: %s", err)
 	}
 
-	d.SetId(hash(file.Name()))
+	if err := p.setTimes(d, path); err != nil {
+		return err
+	}
 
-	return nil
+	d.SetId(d.Get("path").(string))
+
+	log.Info("created file (mode %s, owner %s:%s)", d.Get("mode"), d.Get("user"), d.Get("group"))
+
+	newHash, err := p.hookHash(d, "create", path)
+	if err != nil {
+		return err
+	}
+
+	return p.runHook(d, "create", path, "", newHash, log)
 }
 
 func resourceFilesystemFileRead(d *schema.ResourceData, meta interface{}) error {
 	p := meta.(filesystemProvider)
 
-	p.log.Debug("calling resourceFilesystemFileRead()")
+	log := p.resourceLogger("filesystem_file", "read", d)
+	log.Debug("calling resourceFilesystemFileRead()")
 
-	fileInfo, err := os.Stat(d.Get("path").(string))
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	fileInfo, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			d.SetId("")
@@ -120,82 +262,187 @@ func resourceFilesystemFileRead(d *schema.ResourceData, meta interface{}) error
 		return err
 	}
 	d.Set("mode", fmt.Sprintf("%#o", fileInfo.Mode()))
+	readTimes(d, fileInfo)
 
-	fileContent, err := ioutil.ReadFile(d.Get("path").(string))
+	attribute := contentAttribute(d)
+	content, err := p.readStateContent(path, attribute == "content" && d.Get("store_content_in_state").(bool))
 	if err != nil {
 		return err
 	}
-	d.Set("content", hash(string(fileContent)))
+	d.Set(attribute, content)
+
+	// Content line changes only describe the update they were planned for
+	d.Set("content_diff", "")
 
-	u, err := user.LookupId(fmt.Sprintf("%d", fileInfo.Sys().(*syscall.Stat_t).Uid))
+	username, err := p.accounts.lookupUserID(int(fileInfo.Sys().(*syscall.Stat_t).Uid))
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner user information: %s", err)
 	}
-	d.Set("user", u.Username)
+	d.Set("user", username)
 
-	g, err := user.LookupGroupId(fmt.Sprintf("%d", fileInfo.Sys().(*syscall.Stat_t).Gid))
+	groupname, err := p.accounts.lookupGroupID(int(fileInfo.Sys().(*syscall.Stat_t).Gid))
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner group information: %s", err)
 	}
-	d.Set("group", g.Name)
+	d.Set("group", groupname)
 
 	return nil
 }
 
 func resourceFilesystemFileUpdate(d *schema.ResourceData, meta interface{}) error {
-	p := meta.(filesystemProvider)
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
 
-	p.log.Debug("calling resourceFilesystemFileUpdate()")
+	log := p.resourceLogger("filesystem_file", "update", d)
+	log.Debug("calling resourceFilesystemFileUpdate()")
 
-	file, err := os.OpenFile(d.Get("path").(string), os.O_RDWR, 0666)
+	path, err := p.resolvePath(d.Get("path").(string))
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	if d.HasChange("mode") {
-		fileMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
+	oldHash, err := p.hookHash(d, "change", path)
+	if err != nil {
+		return err
+	}
+
+	fileMode, _ := strconv.ParseUint(d.Get("mode").(string), 8, 32)
+
+	// Permissions and owner are applied once the new content is validated and installed, for the
+	// file to be left unchanged when validation fails
+	if contentChanged(d) {
+		content, err := fileContent(d)
+		if err != nil {
+			return err
+		}
 
-		if err := file.Chmod(os.FileMode(fileMode)); err != nil {
+		if err := checkContentFormat(contentAttribute(d), d.Get("validate_format").(string), content); err != nil {
 			return err
 		}
+
+		if err := p.writeFile(path, []byte(content), os.FileMode(fileMode), p.contentValidator(d.Get("validate_command").(string))); err != nil {
+			return err
+		}
+
+		log.Info("updated content (%d bytes)", len(content))
+	}
+
+	if d.HasChange("mode") {
+		if err := p.chmod(path, os.FileMode(fileMode)); err != nil {
+			return err
+		}
+
+		log.Info("changed mode to %s", d.Get("mode"))
 	}
 
 	if d.HasChange("user") || d.HasChange("group") {
-		u, err := user.Lookup(d.Get("user").(string))
+		uid, err := p.accounts.lookupUser(d.Get("user").(string))
 		if err != nil {
 			return fmt.Errorf("unable to lookup file owner user information: %s", err)
 		}
-		uid, _ := strconv.Atoi(u.Uid)
 
-		g, err := user.LookupGroup(d.Get("group").(string))
+		gid, err := p.accounts.lookupGroup(d.Get("group").(string))
 		if err != nil {
 			return fmt.Errorf("unable to lookup file owner group information: %s", err)
 		}
-		gid, _ := strconv.Atoi(g.Gid)
 
-		if err := file.Chown(uid, gid); err != nil {
+		if err := p.chown(path, uid, gid); err != nil {
 			return fmt.Errorf("unable to change file user/group: %s", err)
 		}
+
+		log.Info("changed owner to %s:%s", d.Get("user"), d.Get("group"))
+	}
+
+	// Content unchanged, but to be stored differently in state
+	if contentAttribute(d) == "content" && d.HasChange("store_content_in_state") && !contentChanged(d) {
+		content, err := p.readStateContent(path, d.Get("store_content_in_state").(bool))
+		if err != nil {
+			return err
+		}
+		d.Set("content", content)
 	}
 
-	if d.HasChange("content") {
-		if err := file.Truncate(0); err != nil {
+	// Content changes also update the file modification time: timestamps have to be applied last
+	if d.HasChange("mtime") || d.HasChange("atime") || contentChanged(d) {
+		if err := p.setTimes(d, path); err != nil {
 			return err
 		}
 
-		if _, err := file.WriteString(d.Get("content").(string)); err != nil {
+		log.Info("changed access/modification times")
+	}
+
+	if contentChanged(d) || d.HasChange("mode") || d.HasChange("user") || d.HasChange("group") {
+		newHash, err := p.hookHash(d, "change", path)
+		if err != nil {
 			return err
 		}
+
+		return p.runHook(d, "change", path, oldHash, newHash, log)
 	}
 
 	return nil
 }
 
 func resourceFilesystemFileDelete(d *schema.ResourceData, meta interface{}) error {
-	p := meta.(filesystemProvider)
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_file", "delete", d)
+	log.Debug("calling resourceFilesystemFileDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	oldHash, err := p.hookHash(d, "destroy", path)
+	if err != nil {
+		return err
+	}
 
-	p.log.Debug("calling resourceFilesystemFileDelete()")
+	if err := p.remove(path); err != nil {
+		return err
+	}
+
+	log.Info("removed file")
+
+	return p.runHook(d, "destroy", path, oldHash, "", log)
+}
+
+// digestContentAttributes are the alternatives to the content attribute, always stored in state as
+// digests
+var digestContentAttributes = []string{"sensitive_content", "content_json", "content_yaml"}
 
-	return os.Remove(d.Get("path").(string))
+// contentAttribute returns the attribute holding the file content: content, unless one of its
+// alternatives is set
+func contentAttribute(d *schema.ResourceData) string {
+	for _, attribute := range digestContentAttributes {
+		if _, ok := d.GetOk(attribute); ok {
+			return attribute
+		}
+	}
+	return "content"
+}
+
+// contentChanged reports whether the file content changes
+func contentChanged(d *schema.ResourceData) bool {
+	for _, attribute := range append([]string{"content"}, digestContentAttributes...) {
+		if d.HasChange(attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileContent returns the content to write to the file, structured content being written in
+// canonical form
+func fileContent(d *schema.ResourceData) (string, error) {
+	switch attribute := contentAttribute(d); attribute {
+	case "content_json":
+		return canonicalJSON(d.Get(attribute).(string))
+	case "content_yaml":
+		return canonicalYAML(d.Get(attribute).(string))
+	default:
+		return d.Get(attribute).(string), nil
+	}
 }
@@ -0,0 +1,27 @@
+package filesystem
+
+import (
+	"regexp"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+var fileStateMigrations = []stateMigration{
+	migrateFileStateV0toV1,
+}
+
+var sha256HexRegexp = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// migrateFileStateV0toV1 replaces the path hash ID by the path itself, and prefixes the content
+// hash with the digest algorithm
+func migrateFileStateV0toV1(is *terraform.InstanceState, meta interface{}) error {
+	if err := migrateStateV0toV1(is, meta); err != nil {
+		return err
+	}
+
+	if content := is.Attributes["content"]; sha256HexRegexp.MatchString(content) {
+		is.Attributes["content"] = contentDigestPrefix + content
+	}
+
+	return nil
+}
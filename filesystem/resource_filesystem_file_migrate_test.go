@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestFilesystemFileMigrateState(t *testing.T) {
+	cases := map[string]struct {
+		StateVersion int
+		ID           string
+		Attributes   map[string]string
+		Expected     *terraform.InstanceState
+	}{
+		"v0_1": {
+			StateVersion: 0,
+			ID:           hash("/tmp/testfile"),
+			Attributes: map[string]string{
+				"path":    "/tmp/testfile",
+				"user":    "root",
+				"group":   "root",
+				"mode":    "0600",
+				"content": hash("blah"),
+			},
+			Expected: &terraform.InstanceState{
+				ID: "/tmp/testfile",
+				Attributes: map[string]string{
+					"path":    "/tmp/testfile",
+					"user":    "root",
+					"group":   "root",
+					"mode":    "0600",
+					"content": "sha256:" + hash("blah"),
+				},
+			},
+		},
+		"v1": {
+			StateVersion: 1,
+			ID:           "/tmp/testfile",
+			Attributes: map[string]string{
+				"path":    "/tmp/testfile",
+				"content": "sha256:" + hash("blah"),
+			},
+			Expected: &terraform.InstanceState{
+				ID: "/tmp/testfile",
+				Attributes: map[string]string{
+					"path":    "/tmp/testfile",
+					"content": "sha256:" + hash("blah"),
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		is := &terraform.InstanceState{
+			ID:         tc.ID,
+			Attributes: tc.Attributes,
+		}
+
+		is, err := resourceFile().MigrateState(tc.StateVersion, is, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+
+		if !reflect.DeepEqual(is, tc.Expected) {
+			t.Fatalf("%s: migrated state (%#v) different from expected state (%#v)", name, is, tc.Expected)
+		}
+	}
+}
+
+func TestFilesystemFileMigrateStateEmpty(t *testing.T) {
+	is, err := resourceFile().MigrateState(0, &terraform.InstanceState{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if is.ID != "" || len(is.Attributes) != 0 {
+		t.Fatalf("migrated empty state (%#v) not empty", is)
+	}
+
+	if _, err := resourceFile().MigrateState(2, &terraform.InstanceState{ID: "/tmp/testfile"}, nil); err == nil {
+		t.Fatalf("expected error migrating state from unknown schema version")
+	}
+}
@@ -1,12 +1,19 @@
 package filesystem
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/user"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/terraform"
@@ -74,7 +81,7 @@ func testFilesystemFileCreate(state *terraform.State) error {
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner user information: %s", err)
 	}
-	currentUsername, _ := getCurrentUsername()
+	currentUsername, _ := accountDatabase{}.currentUsername()
 	if u.Username != currentUsername {
 		return fmt.Errorf("test file username (%q) different from expected username (%q)",
 			u.Username,
@@ -85,7 +92,7 @@ func testFilesystemFileCreate(state *terraform.State) error {
 	if err != nil {
 		return fmt.Errorf("unable to lookup file owner group information: %s", err)
 	}
-	currentUserGroupname, _ := getCurrentUserGroupname()
+	currentUserGroupname, _ := accountDatabase{}.currentUserGroupname()
 	if g.Name != currentUserGroupname {
 		return fmt.Errorf("test file groupname (%q) different from expected groupname (%q)",
 			g.Name,
@@ -106,6 +113,16 @@ func testFilesystemFileCreate(state *terraform.State) error {
 			hash("blah"))
 	}
 
+	if rs.Primary.ID != rs.Primary.Attributes["path"] {
+		return fmt.Errorf("test file ID (%q) different from expected ID (%q)", rs.Primary.ID, rs.Primary.Attributes["path"])
+	}
+
+	if rs.Primary.Attributes["content"] != "sha256:"+hash("blah") {
+		return fmt.Errorf("test file content state (%q) different from expected content state (%q)",
+			rs.Primary.Attributes["content"],
+			"sha256:"+hash("blah"))
+	}
+
 	return nil
 }
 
@@ -162,3 +179,1350 @@ func testFilesystemFileDelete(state *terraform.State) error {
 
 	return fmt.Errorf("test file not deleted properly")
 }
+
+func TestAccFilesystemFileDefaultMode(t *testing.T) {
+	const (
+		fileCreateDefaultModeResource = `
+provider "filesystem" {
+  default_file_mode = "0640"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+}
+`
+
+		fileUpdateDefaultModeResource = `
+provider "filesystem" {
+  default_file_mode = "0644"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileMode(0640)),
+				Config: fileCreateDefaultModeResource,
+			},
+			resource.TestStep{
+				// Permissions changed outside of Terraform are a drift from the default mode
+				PreConfig:          func() { os.Chmod("/tmp/testfile", 0600) },
+				Config:             fileCreateDefaultModeResource,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileMode(0640)),
+				Config: fileCreateDefaultModeResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileMode(0644)),
+				Config: fileUpdateDefaultModeResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileMode(expected os.FileMode) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		fileInfo, err := os.Stat("/tmp/testfile")
+		if err != nil {
+			return err
+		}
+
+		if fileInfo.Mode() != expected {
+			return fmt.Errorf("test file mode (%s) different from expected mode (%s)", fileInfo.Mode(), expected)
+		}
+
+		return nil
+	}
+}
+
+func TestAccFilesystemFileTimes(t *testing.T) {
+	const (
+		fileCreateTimesResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+  mtime = "2018-02-23T01:00:00+01:00"
+  atime = "2018-02-24T00:00:00Z"
+}
+`
+
+		fileUpdateContentTimesResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "yay"
+  mtime = "2018-02-23T01:00:00+01:00"
+  atime = "2018-02-24T00:00:00Z"
+}
+`
+
+		fileUpdateContentNoTimesResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileTimes),
+				Config: fileCreateTimesResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileTimes),
+				Config: fileUpdateContentTimesResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileCurrentTimes),
+				Config: fileUpdateContentNoTimesResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileTimes(state *terraform.State) error {
+	rs, ok := state.RootModule().Resources["filesystem_file.test"]
+	if !ok {
+		return fmt.Errorf("Not found: %s", "filesystem_file.test")
+	}
+
+	fileInfo, err := os.Stat(rs.Primary.Attributes["path"])
+	if err != nil {
+		return err
+	}
+
+	expectedMtime := time.Date(2018, 2, 23, 0, 0, 0, 0, time.UTC)
+	if !fileInfo.ModTime().Equal(expectedMtime) {
+		return fmt.Errorf("test file mtime (%s) different from expected mtime (%s)",
+			fileInfo.ModTime(),
+			expectedMtime)
+	}
+
+	expectedAtime := time.Date(2018, 2, 24, 0, 0, 0, 0, time.UTC)
+	if !fileAccessTime(fileInfo).Equal(expectedAtime) {
+		return fmt.Errorf("test file atime (%s) different from expected atime (%s)",
+			fileAccessTime(fileInfo),
+			expectedAtime)
+	}
+
+	return nil
+}
+
+func testFilesystemFileCurrentTimes(state *terraform.State) error {
+	rs, ok := state.RootModule().Resources["filesystem_file.test"]
+	if !ok {
+		return fmt.Errorf("Not found: %s", "filesystem_file.test")
+	}
+
+	fileInfo, err := os.Stat(rs.Primary.Attributes["path"])
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.ModTime().Before(time.Now().Add(-time.Hour)) {
+		return fmt.Errorf("test file mtime (%s) not updated by the content change", fileInfo.ModTime())
+	}
+
+	if mtime := formatTimestamp(fileInfo.ModTime()); rs.Primary.Attributes["mtime"] != mtime {
+		return fmt.Errorf("test file mtime attribute (%q) different from expected mtime (%q)",
+			rs.Primary.Attributes["mtime"],
+			mtime)
+	}
+
+	return nil
+}
+
+func TestAccFilesystemFileRootDir(t *testing.T) {
+	const (
+		fileCreateRootDirResource = `
+provider "filesystem" {
+  root_dir = "/tmp/testroot"
+}
+
+resource "filesystem_file" "test" {
+  path = "/testfile"
+  content = "blah"
+}
+`
+
+		fileCreateDotDotEscapeResource = `
+provider "filesystem" {
+  root_dir = "/tmp/testroot"
+}
+
+resource "filesystem_file" "test" {
+  path = "/../testfile"
+  content = "blah"
+}
+`
+
+		fileCreateSymlinkEscapeResource = `
+provider "filesystem" {
+  root_dir = "/tmp/testroot"
+}
+
+resource "filesystem_file" "test" {
+  path = "/escape/testfile"
+  content = "blah"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() {
+					os.RemoveAll("/tmp/testroot")
+					os.Mkdir("/tmp/testroot", 0755)
+					os.Symlink("/tmp", "/tmp/testroot/escape")
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileRootDir),
+				Config: fileCreateRootDirResource,
+			},
+			resource.TestStep{
+				Config:      fileCreateDotDotEscapeResource,
+				ExpectError: regexp.MustCompile(`path "/../testfile" escapes root directory "/tmp/testroot"`),
+			},
+			resource.TestStep{
+				Config:      fileCreateSymlinkEscapeResource,
+				ExpectError: regexp.MustCompile(`path "/escape/testfile" escapes root directory "/tmp/testroot" through a symbolic link`),
+			},
+		},
+		CheckDestroy: testFilesystemFileRootDirDelete,
+	})
+}
+
+func testFilesystemFileRootDir(state *terraform.State) error {
+	fileContent, err := ioutil.ReadFile("/tmp/testroot/testfile")
+	if err != nil {
+		return err
+	}
+	if hash(string(fileContent)) != hash("blah") {
+		return fmt.Errorf("test file content hash (%q) different from expected hash (%q)",
+			hash(string(fileContent)),
+			hash("blah"))
+	}
+
+	return nil
+}
+
+func testFilesystemFileRootDirDelete(state *terraform.State) error {
+	for _, path := range []string{"/tmp/testroot/testfile", "/tmp/testfile"} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("test file %q not deleted properly", path)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.RemoveAll("/tmp/testroot")
+}
+
+func TestAccFilesystemFileAccountFiles(t *testing.T) {
+	const (
+		fileCreateAccountFilesResource = `
+provider "filesystem" {
+  root_dir = "/tmp/testroot"
+  root_dir_accounts = true
+}
+
+resource "filesystem_file" "test" {
+  path = "/testfile"
+  user = "testuser"
+  group = "testgroup"
+  content = "blah"
+}
+
+# Owned by the current user, missing from the root directory accounts
+resource "filesystem_file" "default" {
+  path = "/testdefault"
+  content = "blah"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() {
+					os.RemoveAll("/tmp/testroot")
+					os.MkdirAll("/tmp/testroot/etc", 0755)
+					ioutil.WriteFile("/tmp/testroot/etc/passwd", []byte("testuser:x:4242:4243::/:/bin/false\n"), 0644)
+					ioutil.WriteFile("/tmp/testroot/etc/group", []byte("testgroup:x:4243:\n"), 0644)
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileAccountFiles),
+				Config: fileCreateAccountFilesResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileRootDirDelete,
+	})
+}
+
+func testFilesystemFileAccountFiles(state *terraform.State) error {
+	fileInfo, err := os.Stat("/tmp/testroot/testfile")
+	if err != nil {
+		return err
+	}
+
+	if uid := fileInfo.Sys().(*syscall.Stat_t).Uid; uid != 4242 {
+		return fmt.Errorf("test file uid (%d) different from expected uid (%d)", uid, 4242)
+	}
+
+	if gid := fileInfo.Sys().(*syscall.Stat_t).Gid; gid != 4243 {
+		return fmt.Errorf("test file gid (%d) different from expected gid (%d)", gid, 4243)
+	}
+
+	rs, ok := state.RootModule().Resources["filesystem_file.default"]
+	if !ok {
+		return fmt.Errorf("Not found: %s", "filesystem_file.default")
+	}
+
+	if user, expected := rs.Primary.Attributes["user"], strconv.Itoa(os.Getuid()); user != expected {
+		return fmt.Errorf("test file user (%q) different from expected user (%q)", user, expected)
+	}
+
+	if group, expected := rs.Primary.Attributes["group"], strconv.Itoa(os.Getgid()); group != expected {
+		return fmt.Errorf("test file group (%q) different from expected group (%q)", group, expected)
+	}
+
+	return nil
+}
+
+func TestAccFilesystemFilePathPolicy(t *testing.T) {
+	const (
+		fileCreateDeniedResource = `
+provider "filesystem" {
+  allowed_paths = ["/tmp"]
+  denied_paths = ["/tmp/testdenied*"]
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testdenied/testfile"
+  content = "blah"
+}
+`
+
+		fileCreateNotAllowedResource = `
+provider "filesystem" {
+  allowed_paths = ["/tmp"]
+  denied_paths = ["/tmp/testdenied*"]
+}
+
+resource "filesystem_file" "test" {
+  path = "/var/tmp/testfile"
+  content = "blah"
+}
+`
+
+		fileCreateDeniedTargetResource = `
+provider "filesystem" {
+  allowed_paths = ["/tmp"]
+  denied_paths = ["/tmp/testdenied*"]
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testlink"
+  content = "blah"
+}
+`
+
+		directoryCreateNotAllowedParentsResource = `
+provider "filesystem" {
+  allowed_paths = ["/tmp/testparents/allowed"]
+}
+
+resource "filesystem_directory" "test" {
+  path = "/tmp/testparents/allowed"
+  create_parents = true
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config:      fileCreateDeniedResource,
+				ExpectError: regexp.MustCompile(`path "/tmp/testdenied/testfile" denied by denied_paths rule "/tmp/testdenied\*"`),
+			},
+			resource.TestStep{
+				Config:      fileCreateNotAllowedResource,
+				ExpectError: regexp.MustCompile(`path "/var/tmp/testfile" not allowed by any allowed_paths rule`),
+			},
+			resource.TestStep{
+				// Symbolic links are resolved before checking paths
+				PreConfig: func() {
+					os.Mkdir("/tmp/testdenied", 0755)
+					ioutil.WriteFile("/tmp/testdenied/testfile", []byte("denied"), 0644)
+					os.Symlink("/tmp/testdenied/testfile", "/tmp/testlink")
+				},
+				Config:      fileCreateDeniedTargetResource,
+				ExpectError: regexp.MustCompile(`path "/tmp/testdenied/testfile" denied by denied_paths rule "/tmp/testdenied\*"`),
+			},
+			resource.TestStep{
+				// Parent directories are checked as well
+				PreConfig: func() {
+					if content, _ := ioutil.ReadFile("/tmp/testdenied/testfile"); string(content) != "denied" {
+						t.Fatalf("denied file content changed (%q)", content)
+					}
+					os.RemoveAll("/tmp/testparents")
+				},
+				Config:      directoryCreateNotAllowedParentsResource,
+				ExpectError: regexp.MustCompile(`path "/tmp/testparents" not allowed by any allowed_paths rule`),
+			},
+		},
+	})
+
+	os.Remove("/tmp/testlink")
+	os.RemoveAll("/tmp/testdenied")
+
+	if _, err := os.Stat("/tmp/testparents"); !os.IsNotExist(err) {
+		t.Fatal("directory created outside allowed paths")
+	}
+}
+
+func TestAccFilesystemFileLogging(t *testing.T) {
+	const (
+		fileCreateLoggingResource = `
+provider "filesystem" {
+  log_level = "info"
+  log_path = "/tmp/testprovider.log"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { os.Remove("/tmp/testprovider.log") },
+				Check:     resource.ComposeAggregateTestCheckFunc(testFilesystemFileLogging),
+				Config:    fileCreateLoggingResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileLogging(state *terraform.State) error {
+	logContent, err := ioutil.ReadFile("/tmp/testprovider.log")
+	if err != nil {
+		return err
+	}
+
+	expected := regexp.MustCompile(`INFO: resource=filesystem_file path="/tmp/testfile" operation=create: created file`)
+	if !expected.Match(logContent) {
+		return fmt.Errorf("test log file content (%q) doesn't match expected pattern (%q)", logContent, expected)
+	}
+
+	if regexp.MustCompile("DEBUG:").Match(logContent) {
+		return fmt.Errorf("test log file content (%q) contains debug messages", logContent)
+	}
+
+	return nil
+}
+
+func TestAccFilesystemFileAuditLog(t *testing.T) {
+	const (
+		fileCreateAuditLogResource = `
+provider "filesystem" {
+  audit_log = "/tmp/testaudit.log"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+  mode = "0600"
+}
+`
+
+		fileUpdateContentAuditLogResource = `
+provider "filesystem" {
+  audit_log = "/tmp/testaudit.log"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "yay"
+  mode = "0600"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { os.Remove("/tmp/testaudit.log") },
+				Config:    fileCreateAuditLogResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileAuditLog),
+				Config: fileUpdateContentAuditLogResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileAuditLog(state *terraform.State) error {
+	auditLog, err := os.Open("/tmp/testaudit.log")
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+
+	var entries []auditEntry
+	for decoder := json.NewDecoder(auditLog); decoder.More(); {
+		var entry auditEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 3 {
+		return fmt.Errorf("test audit log entries count (%d) different from expected count (%d)", len(entries), 3)
+	}
+
+	create, write := entries[0], entries[2]
+
+	if create.Operation != "create" || create.Path != "/tmp/testfile" || create.Before != nil {
+		return fmt.Errorf("unexpected test audit log create entry: %+v", create)
+	}
+	if create.After == nil || create.After.Mode != "0600" || create.After.SHA256 != hash("blah") {
+		return fmt.Errorf("unexpected test audit log create entry after state: %+v", create.After)
+	}
+
+	if entries[1].Operation != "chown" {
+		return fmt.Errorf("unexpected test audit log chown entry: %+v", entries[1])
+	}
+
+	if write.Operation != "write" || write.Before == nil || write.Before.SHA256 != hash("blah") {
+		return fmt.Errorf("unexpected test audit log write entry: %+v", write)
+	}
+	if write.After == nil || write.After.SHA256 != hash("yay") {
+		return fmt.Errorf("unexpected test audit log write entry after state: %+v", write.After)
+	}
+
+	return nil
+}
+
+func TestAccFilesystemFileTransactional(t *testing.T) {
+	const (
+		fileCreateTransactionalResource = `
+provider "filesystem" {
+  transactional = true
+  journal_dir = "/tmp/testjournal"
+  journal_retention = 0
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+  mode = "0600"
+}
+`
+
+		fileUpdateFailedTransactionalResource = `
+provider "filesystem" {
+  transactional = true
+  journal_dir = "/tmp/testjournal"
+  journal_retention = 0
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "yay"
+  mode = "0644"
+}
+
+resource "filesystem_file" "failed" {
+  path = "/tmp/testfailed"
+  content = "blah"
+  on_create_command = "exit 1"
+
+  depends_on = ["filesystem_file.test"]
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { os.RemoveAll("/tmp/testjournal") },
+				Check:     resource.ComposeAggregateTestCheckFunc(testFilesystemFileTransactionalJournal(1)),
+				Config:    fileCreateTransactionalResource,
+			},
+			resource.TestStep{
+				Config:      fileUpdateFailedTransactionalResource,
+				ExpectError: regexp.MustCompile("filesystem changes rolled back"),
+			},
+			resource.TestStep{
+				// Only the changes of the failed resource are rolled back, the other resource changes
+				// being recorded in the state
+				PreConfig: func() {
+					if err := testFilesystemFileTransactional(nil); err != nil {
+						t.Fatal(err)
+					}
+				},
+				// The runs of the previous steps are removed when the run of this step starts
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileTransactionalJournal(1)),
+				Config: fileCreateTransactionalResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileTransactional(state *terraform.State) error {
+	content, err := ioutil.ReadFile("/tmp/testfile")
+	if err != nil {
+		return err
+	}
+
+	if string(content) != "yay" {
+		return fmt.Errorf("test file content (%q) different from expected content (%q)", content, "yay")
+	}
+
+	fileInfo, err := os.Stat("/tmp/testfile")
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Mode() != 0644 {
+		return fmt.Errorf("test file mode (%s) different from expected mode (%s)", fileInfo.Mode(), os.FileMode(0644))
+	}
+
+	if _, err := os.Stat("/tmp/testfailed"); !os.IsNotExist(err) {
+		return fmt.Errorf("failed test file not removed on rollback (%v)", err)
+	}
+
+	// The journal entries of the failed resource are removed along with the changes
+	entries, err := filepath.Glob("/tmp/testjournal/*/*.json")
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("no entry found in test journal")
+	}
+
+	for _, entry := range entries {
+		data, err := ioutil.ReadFile(entry)
+		if err != nil {
+			return err
+		}
+
+		var s snapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+
+		if s.Path != "/tmp/testfile" {
+			return fmt.Errorf("test journal entry %q path (%q) different from expected path (%q)", entry, s.Path, "/tmp/testfile")
+		}
+	}
+
+	return nil
+}
+
+func testFilesystemFileTransactionalJournal(expected int) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		runs, err := ioutil.ReadDir("/tmp/testjournal")
+		if err != nil {
+			return err
+		}
+
+		if len(runs) != expected {
+			return fmt.Errorf("test journal runs count (%d) different from expected count (%d)", len(runs), expected)
+		}
+
+		for _, run := range runs {
+			entries, err := ioutil.ReadDir(filepath.Join("/tmp/testjournal", run.Name()))
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				if entry.Mode() != 0600 {
+					return fmt.Errorf("test snapshot %q mode (%s) different from expected mode (%s)", entry.Name(), entry.Mode(), os.FileMode(0600))
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+func TestAccFilesystemFileTimeout(t *testing.T) {
+	const (
+		fileCreateTimeoutResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+  mode = "0600"
+
+  timeouts {
+    create = "1ns"
+  }
+}
+`
+
+		fileCreateResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+  mode = "0600"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config:      fileCreateTimeoutResource,
+				ExpectError: regexp.MustCompile("operation interrupted: context deadline exceeded"),
+			},
+			resource.TestStep{
+				PreConfig: func() {
+					if err := testFilesystemFileTimeout(nil); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileCreate),
+				Config: fileCreateResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileTimeout(state *terraform.State) error {
+	if _, err := os.Stat("/tmp/testfile"); !os.IsNotExist(err) {
+		return fmt.Errorf("test file has been created despite the operation timeout")
+	}
+
+	if tempFiles, _ := filepath.Glob("/tmp/.testfile.*"); len(tempFiles) > 0 {
+		return fmt.Errorf("test file temporary files left behind: %q", tempFiles)
+	}
+
+	return nil
+}
+
+func TestAccFilesystemFileStoreContent(t *testing.T) {
+	const (
+		fileCreateStoreContentResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah\n"
+  store_content_in_state = true
+}
+`
+
+		fileUpdateStoreContentResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "yay\n"
+  store_content_in_state = true
+}
+`
+
+		fileUpdateNoStoreContentResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "yay\n"
+}
+`
+	)
+
+	fileUpdateLargeStoreContentResource := fmt.Sprintf(`
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "%s"
+  store_content_in_state = true
+}
+`, strings.Repeat("blah", maxStateContentSize))
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileStoreContent("blah\n")),
+				Config: fileCreateStoreContentResource,
+			},
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemFileStoreContent("yay\n"),
+					resource.TestCheckResourceAttr("filesystem_file.test", "content_diff", "-blah\n+yay\n"),
+				),
+				Config: fileUpdateStoreContentResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileStoreContent(contentDigest("yay\n"))),
+				Config: fileUpdateNoStoreContentResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileStoreContent(contentDigest(strings.Repeat("blah", maxStateContentSize)))),
+				Config: fileUpdateLargeStoreContentResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileStoreContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		rs, ok := state.RootModule().Resources["filesystem_file.test"]
+		if !ok {
+			return fmt.Errorf("Not found: %s", "filesystem_file.test")
+		}
+
+		if rs.Primary.Attributes["content"] != expected {
+			return fmt.Errorf("test file content state (%q) different from expected content state (%q)",
+				rs.Primary.Attributes["content"],
+				expected)
+		}
+
+		return nil
+	}
+}
+
+func TestAccFilesystemFileSensitiveContent(t *testing.T) {
+	const (
+		fileCreateSensitiveContentResource = `
+provider "filesystem" {
+  log_level = "debug"
+  log_path = "/tmp/testprovider.log"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  sensitive_content = "s3cr3t"
+  store_content_in_state = true
+}
+`
+
+		fileUpdateSensitiveContentResource = `
+provider "filesystem" {
+  log_level = "debug"
+  log_path = "/tmp/testprovider.log"
+}
+
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  sensitive_content = "s3cr3t!"
+  store_content_in_state = true
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { os.Remove("/tmp/testprovider.log") },
+				Check:     resource.ComposeAggregateTestCheckFunc(testFilesystemFileSensitiveContent("s3cr3t")),
+				Config:    fileCreateSensitiveContentResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileSensitiveContent("s3cr3t!")),
+				Config: fileUpdateSensitiveContentResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileSensitiveContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		rs, ok := state.RootModule().Resources["filesystem_file.test"]
+		if !ok {
+			return fmt.Errorf("Not found: %s", "filesystem_file.test")
+		}
+
+		fileInfo, err := os.Stat(rs.Primary.Attributes["path"])
+		if err != nil {
+			return err
+		}
+
+		if fileInfo.Mode() != 0600 {
+			return fmt.Errorf("test file mode (%s) different from expected mode (%s)", fileInfo.Mode(), os.FileMode(0600))
+		}
+
+		fileContent, err := ioutil.ReadFile(rs.Primary.Attributes["path"])
+		if err != nil {
+			return err
+		}
+
+		if string(fileContent) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", fileContent, expected)
+		}
+
+		if rs.Primary.Attributes["sensitive_content"] != contentDigest(expected) {
+			return fmt.Errorf("test file sensitive content state (%q) different from expected content state (%q)",
+				rs.Primary.Attributes["sensitive_content"],
+				contentDigest(expected))
+		}
+
+		for k, v := range rs.Primary.Attributes {
+			if strings.Contains(v, "s3cr3t") {
+				return fmt.Errorf("test file sensitive content stored in state attribute %q", k)
+			}
+		}
+
+		providerLog, err := ioutil.ReadFile("/tmp/testprovider.log")
+		if err != nil {
+			return err
+		}
+
+		if strings.Contains(string(providerLog), "s3cr3t") {
+			return fmt.Errorf("test file sensitive content written to provider log")
+		}
+
+		return nil
+	}
+}
+
+func TestAccFilesystemFileTemplate(t *testing.T) {
+	const (
+		fileCreateTemplateResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  template = "Hello {{.name}}\n"
+
+  vars {
+    name = "world"
+  }
+}
+`
+
+		fileUpdateTemplateSourceResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  template_source = "/tmp/testtemplate"
+
+  vars {
+    name = "world"
+  }
+}
+`
+
+		fileUpdateUndefinedVarTemplateResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  template = "Hello {{.name}}\n{{.undefined}}\n"
+
+  vars {
+    name = "world"
+  }
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileTemplate("Hello world\n")),
+				Config: fileCreateTemplateResource,
+			},
+			resource.TestStep{
+				PreConfig: func() { ioutil.WriteFile("/tmp/testtemplate", []byte("Goodbye {{.name}}\n"), 0644) },
+				Check:     resource.ComposeAggregateTestCheckFunc(testFilesystemFileTemplate("Goodbye world\n")),
+				Config:    fileUpdateTemplateSourceResource,
+			},
+			resource.TestStep{
+				// Template source changes are detected at plan time
+				PreConfig: func() { ioutil.WriteFile("/tmp/testtemplate", []byte("Farewell {{.name}}\n"), 0644) },
+				Check:     resource.ComposeAggregateTestCheckFunc(testFilesystemFileTemplate("Farewell world\n")),
+				Config:    fileUpdateTemplateSourceResource,
+			},
+			resource.TestStep{
+				PreConfig:   func() { ioutil.WriteFile("/tmp/testtemplate", []byte("Farewell {{.name}}\n{{if}}\n"), 0644) },
+				Config:      fileUpdateTemplateSourceResource,
+				ExpectError: regexp.MustCompile(`template: /tmp/testtemplate:2: missing value for if`),
+			},
+			resource.TestStep{
+				Config:      fileUpdateUndefinedVarTemplateResource,
+				ExpectError: regexp.MustCompile(`template: filesystem_file.test:2:2: executing "filesystem_file.test" at <.undefined>: map has no entry for key "undefined"`),
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileTemplate(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		rs, ok := state.RootModule().Resources["filesystem_file.test"]
+		if !ok {
+			return fmt.Errorf("Not found: %s", "filesystem_file.test")
+		}
+
+		fileContent, err := ioutil.ReadFile(rs.Primary.Attributes["path"])
+		if err != nil {
+			return err
+		}
+
+		if string(fileContent) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", fileContent, expected)
+		}
+
+		if rs.Primary.Attributes["content"] != contentDigest(expected) {
+			return fmt.Errorf("test file content state (%q) different from expected content state (%q)",
+				rs.Primary.Attributes["content"],
+				contentDigest(expected))
+		}
+
+		return nil
+	}
+}
+
+func TestAccFilesystemFileStructuredContent(t *testing.T) {
+	const (
+		fileCreateContentJSONResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content_json = "{\"b\": [1, 2.50], \"a\": {\"d\": \"<tag>\", \"c\": true}}"
+}
+`
+
+		// Semantically equal to the previous content: no diff
+		fileUpdateContentJSONResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content_json = <<EOF
+{
+    "a": {"c": true, "d": "<tag>"},
+    "b": [1, 2.50]
+}
+EOF
+}
+`
+
+		fileUpdateContentYAMLResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content_yaml = <<EOF
+# comment
+b:   [1, 2]
+a:
+    c: true
+EOF
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemFileStructuredContent("content_json",
+					"{\n  \"a\": {\n    \"c\": true,\n    \"d\": \"<tag>\"\n  },\n  \"b\": [\n    1,\n    2.50\n  ]\n}\n")),
+				Config: fileCreateContentJSONResource,
+			},
+			resource.TestStep{
+				PlanOnly: true,
+				Config:   fileUpdateContentJSONResource,
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileStructuredContent("content_yaml", "a:\n  c: true\nb:\n  - 1\n  - 2\n")),
+				Config: fileUpdateContentYAMLResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileStructuredContent(attribute, expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		rs, ok := state.RootModule().Resources["filesystem_file.test"]
+		if !ok {
+			return fmt.Errorf("Not found: %s", "filesystem_file.test")
+		}
+
+		fileContent, err := ioutil.ReadFile(rs.Primary.Attributes["path"])
+		if err != nil {
+			return err
+		}
+
+		if string(fileContent) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", fileContent, expected)
+		}
+
+		if rs.Primary.Attributes[attribute] != contentDigest(expected) {
+			return fmt.Errorf("test file %s state (%q) different from expected state (%q)",
+				attribute,
+				rs.Primary.Attributes[attribute],
+				contentDigest(expected))
+		}
+
+		return nil
+	}
+}
+
+func TestAccFilesystemFileValidateFormat(t *testing.T) {
+	const (
+		fileCreateValidateFormatResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "{\"a\": 1}"
+  validate_format = "json"
+}
+`
+
+		fileUpdateInvalidContentResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "{\n\"a\": 1,\n}"
+  validate_format = "json"
+}
+`
+
+		fileUpdateInvalidSensitiveContentResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  sensitive_content = "{\"password\": secret}"
+  validate_format = "json"
+}
+`
+
+		// Content unchanged, but not well-formed in the new format
+		fileUpdateValidateFormatResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "{\"a\": 1}"
+  validate_format = "xml"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileStructuredContent("content", "{\"a\": 1}")),
+				Config: fileCreateValidateFormatResource,
+			},
+			resource.TestStep{
+				Config:      fileUpdateInvalidContentResource,
+				ExpectError: regexp.MustCompile(`content: invalid JSON: line 3: invalid character '}'`),
+			},
+			resource.TestStep{
+				Config:      fileUpdateInvalidSensitiveContentResource,
+				ExpectError: regexp.MustCompile(`sensitive_content: invalid JSON document`),
+			},
+			resource.TestStep{
+				Config:      fileUpdateValidateFormatResource,
+				ExpectError: regexp.MustCompile(`content: invalid XML`),
+			},
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileStructuredContent("content", "{\"a\": 1}")),
+				Config: fileCreateValidateFormatResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func TestAccFilesystemFileValidateCommand(t *testing.T) {
+	const (
+		fileCreateValidateCommandResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "valid"
+  validate_command = "grep -qx valid %s || { echo content not valid; exit 1; }"
+}
+`
+
+		fileUpdateInvalidContentResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "broken"
+  mode = "0600"
+  validate_command = "grep -qx valid %s || { echo content not valid; exit 1; }"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemFileValidateCommand),
+				Config: fileCreateValidateCommandResource,
+			},
+			resource.TestStep{
+				Config:      fileUpdateInvalidContentResource,
+				ExpectError: regexp.MustCompile(`content validation failed, file left unchanged: .*exit status 1\ncontent not valid`),
+			},
+			resource.TestStep{
+				PreConfig: func() {
+					if err := testFilesystemFileValidateCommand(nil); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: fileCreateValidateCommandResource,
+			},
+		},
+		CheckDestroy: testFilesystemFileDelete,
+	})
+}
+
+func testFilesystemFileValidateCommand(state *terraform.State) error {
+	content, err := ioutil.ReadFile("/tmp/testfile")
+	if err != nil {
+		return err
+	}
+
+	if string(content) != "valid" {
+		return fmt.Errorf("test file content (%q) different from expected content (%q)", content, "valid")
+	}
+
+	fileInfo, err := os.Stat("/tmp/testfile")
+	if err != nil {
+		return err
+	}
+
+	if fileInfo.Mode() != 0644 {
+		return fmt.Errorf("test file mode (%s) different from expected mode (%s)", fileInfo.Mode(), os.FileMode(0644))
+	}
+
+	tempFiles, err := filepath.Glob("/tmp/.testfile.*")
+	if err != nil {
+		return err
+	}
+
+	if len(tempFiles) != 0 {
+		return fmt.Errorf("temporary files (%q) left behind", tempFiles)
+	}
+
+	return nil
+}
+
+func TestAccFilesystemFileCommandHooks(t *testing.T) {
+	const (
+		fileCreateCommandHooksResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah"
+  on_create_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+  on_change_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+  on_destroy_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+}
+`
+
+		fileUpdateCommandHooksResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah2"
+  on_create_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+  on_change_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+  on_destroy_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+}
+`
+
+		fileUpdateFailedCommandResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah3"
+  on_change_command = "echo reload failed; exit 3"
+  on_destroy_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+}
+`
+
+		fileUpdateTimeoutCommandResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah4"
+  on_change_command = "sleep 10"
+  on_destroy_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+  command_timeout = "100ms"
+}
+`
+
+		fileUpdateWarnCommandResource = `
+resource "filesystem_file" "test" {
+  path = "/tmp/testfile"
+  content = "blah5"
+  on_change_command = "echo reload failed; exit 3"
+  on_destroy_command = "echo $FILESYSTEM_EVENT $FILESYSTEM_PATH $FILESYSTEM_OLD_SHA256 $FILESYSTEM_NEW_SHA256 >> /tmp/testhooks.log"
+  command_failure_policy = "warn"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { os.Remove("/tmp/testhooks.log") },
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemFileCommandHooks(
+					"create /tmp/testfile " + hash("blah"))),
+				Config: fileCreateCommandHooksResource,
+			},
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemFileCommandHooks(
+					"create /tmp/testfile "+hash("blah"),
+					"change /tmp/testfile "+hash("blah")+" "+hash("blah2"))),
+				Config: fileUpdateCommandHooksResource,
+			},
+			resource.TestStep{
+				Config:      fileUpdateFailedCommandResource,
+				ExpectError: regexp.MustCompile(`on_change_command failed: .*exit status 3\nreload failed`),
+			},
+			resource.TestStep{
+				Config:      fileUpdateTimeoutCommandResource,
+				ExpectError: regexp.MustCompile(`on_change_command failed: .*operation interrupted`),
+			},
+			resource.TestStep{
+				Config: fileUpdateWarnCommandResource,
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			testFilesystemFileDelete,
+			testFilesystemFileCommandHooks(
+				"create /tmp/testfile "+hash("blah"),
+				"change /tmp/testfile "+hash("blah")+" "+hash("blah2"),
+				"destroy /tmp/testfile "+hash("blah5"))),
+	})
+}
+
+func testFilesystemFileCommandHooks(expected ...string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testhooks.log")
+		if err != nil {
+			return err
+		}
+
+		if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); !reflect.DeepEqual(lines, expected) {
+			return fmt.Errorf("test hooks log (%q) different from expected log (%q)", lines, expected)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,213 @@
+package filesystem
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceHostsEntry() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the hosts file, created if needed",
+				Optional:    true,
+				Default:     "/etc/hosts",
+				ForceNew:    true,
+			},
+			"ip": {
+				Type:        schema.TypeString,
+				Description: "IP address of the entry",
+				Required:    true,
+				ForceNew:    true,
+				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+					if net.ParseIP(i.(string)) == nil {
+						errors = append(errors, fmt.Errorf("%q: invalid IP address", k))
+					}
+					return
+				},
+			},
+			"hostnames": {
+				Type:        schema.TypeList,
+				Description: "Hostnames of the IP address, merged into its existing entry if any",
+				Required:    true,
+				ForceNew:    false,
+				MinItems:    1,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"added_hostnames": {
+				Type:        schema.TypeList,
+				Description: "Hostnames added by the resource, the only ones removed on destroy",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemHostsEntryCreate,
+		Read:   resourceFilesystemHostsEntryRead,
+		Update: resourceFilesystemHostsEntryUpdate,
+		Delete: resourceFilesystemHostsEntryDelete,
+	}
+}
+
+func resourceFilesystemHostsEntryCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_hosts_entry", "create", d)
+	log.Debug("calling resourceFilesystemHostsEntryCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	ip, names := net.ParseIP(d.Get("ip").(string)), hostsEntryNames(d.Get("hostnames"))
+
+	var added []string
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		added = hostsMissing(content, ip, names)
+		return hostsSet(content, ip, names), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", d.Get("path"), d.Get("ip")))
+	d.Set("added_hostnames", added)
+
+	log.Info("mapped %s to %s", d.Get("ip"), strings.Join(names, " "))
+
+	return nil
+}
+
+func resourceFilesystemHostsEntryRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_hosts_entry", "read", d)
+	log.Debug("calling resourceFilesystemHostsEntryRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	// Hostnames mapped to the IP address by other means are ignored, and the managed ones keep their
+	// configured spelling as they are compared case-insensitively
+	existing := hostsGet(content, net.ParseIP(d.Get("ip").(string)))
+
+	var names []string
+	for _, name := range hostsEntryNames(d.Get("hostnames")) {
+		if containsFold(existing, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		d.SetId("")
+		return nil
+	}
+	d.Set("hostnames", names)
+
+	return nil
+}
+
+func resourceFilesystemHostsEntryUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_hosts_entry", "update", d)
+	log.Debug("calling resourceFilesystemHostsEntryUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if d.HasChange("hostnames") {
+		ip := net.ParseIP(d.Get("ip").(string))
+
+		// Hostnames dropped from the configuration are unmapped in the same edit that adds the new ones,
+		// unless they were mapped before the resource
+		newNames := hostsEntryNames(d.Get("hostnames"))
+
+		var added, removed []string
+		for _, name := range hostsEntryNames(d.Get("added_hostnames")) {
+			if containsFold(newNames, name) {
+				added = append(added, name)
+			} else {
+				removed = append(removed, name)
+			}
+		}
+
+		err := p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+			content = hostsRemove(content, ip, removed)
+			for _, name := range hostsMissing(content, ip, newNames) {
+				if !containsFold(added, name) {
+					added = append(added, name)
+				}
+			}
+			return hostsSet(content, ip, newNames), nil
+		})
+		if err != nil {
+			return err
+		}
+		d.Set("added_hostnames", added)
+
+		log.Info("mapped %s to %s", d.Get("ip"), strings.Join(newNames, " "))
+	}
+
+	return nil
+}
+
+func resourceFilesystemHostsEntryDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_hosts_entry", "delete", d)
+	log.Debug("calling resourceFilesystemHostsEntryDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	// Hostnames mapped before the resource are left in place
+	ip, names := net.ParseIP(d.Get("ip").(string)), hostsEntryNames(d.Get("added_hostnames"))
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		return hostsRemove(content, ip, names), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("unmapped %s from %s", strings.Join(names, " "), d.Get("ip"))
+
+	return nil
+}
+
+// hostsEntryNames returns the hostnames of a hostnames or added_hostnames attribute value
+func hostsEntryNames(i interface{}) []string {
+	var names []string
+	for _, name := range i.([]interface{}) {
+		names = append(names, name.(string))
+	}
+	return names
+}
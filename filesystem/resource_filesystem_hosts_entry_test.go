@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const testHostsEntryFileContent = `127.0.0.1	localhost
+10.0.0.1	db # primary
+`
+
+func TestAccFilesystemHostsEntry(t *testing.T) {
+	const (
+		hostsEntryCreateResource = `
+resource "filesystem_hosts_entry" "db" {
+  path = "/tmp/testfile.hosts"
+  ip = "10.0.0.1"
+  hostnames = ["db", "db.internal", "postgres"]
+}
+
+resource "filesystem_hosts_entry" "cache" {
+  path = "/tmp/testfile.hosts"
+  ip = "10.0.0.2"
+  hostnames = ["cache"]
+}
+`
+
+		hostsEntryUpdateResource = `
+resource "filesystem_hosts_entry" "db" {
+  path = "/tmp/testfile.hosts"
+  ip = "10.0.0.1"
+  hostnames = ["postgres", "pg"]
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { ioutil.WriteFile("/tmp/testfile.hosts", []byte(testHostsEntryFileContent), 0644) },
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemHostsEntryContent(
+						"127.0.0.1\tlocalhost\n10.0.0.1\tdb db.internal postgres # primary\n10.0.0.2\tcache\n"),
+					resource.TestCheckResourceAttr("filesystem_hosts_entry.db", "added_hostnames.#", "2"),
+					resource.TestCheckResourceAttr("filesystem_hosts_entry.db", "added_hostnames.0", "db.internal"),
+					resource.TestCheckResourceAttr("filesystem_hosts_entry.db", "added_hostnames.1", "postgres"),
+				),
+				Config: hostsEntryCreateResource,
+			},
+			resource.TestStep{
+				// Destroyed entries and hostnames no longer managed are removed, unless they were mapped
+				// before the resources
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemHostsEntryContent("127.0.0.1\tlocalhost\n10.0.0.1\tdb postgres pg # primary\n"),
+					resource.TestCheckResourceAttr("filesystem_hosts_entry.db", "added_hostnames.#", "2"),
+					resource.TestCheckResourceAttr("filesystem_hosts_entry.db", "added_hostnames.0", "postgres"),
+					resource.TestCheckResourceAttr("filesystem_hosts_entry.db", "added_hostnames.1", "pg"),
+				),
+				Config: hostsEntryUpdateResource,
+			},
+			resource.TestStep{
+				// Hostnames removed outside of Terraform are added again
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.hosts", []byte("127.0.0.1\tlocalhost\n10.0.0.1\tdb pg\n"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemHostsEntryContent(
+					"127.0.0.1\tlocalhost\n10.0.0.1\tdb pg postgres\n")),
+				Config: hostsEntryUpdateResource,
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(testFilesystemHostsEntryContent("127.0.0.1\tlocalhost\n10.0.0.1\tdb\n")),
+	})
+
+	os.Remove("/tmp/testfile.hosts")
+}
+
+func testFilesystemHostsEntryContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.hosts")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,189 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceINIValue() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the INI file, created if needed",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"section": {
+				Type:        schema.TypeString,
+				Description: "Section of the key, created if needed (default: the keys before the first section)",
+				Optional:    true,
+				Default:     "",
+				ForceNew:    true,
+			},
+			"key": {
+				Type:        schema.TypeString,
+				Description: "Key to set",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Description: "Value of the key, written verbatim",
+				Required:    true,
+				ForceNew:    false,
+			},
+			"restore_on_destroy": {
+				Type:        schema.TypeBool,
+				Description: "Restore the previous value of the key on destroy, instead of removing the key",
+				Optional:    true,
+				Default:     false,
+				ForceNew:    false,
+			},
+			"previous_value": {
+				Type:        schema.TypeString,
+				Description: "Value of the key before it was managed",
+				Computed:    true,
+			},
+			"previous_value_exists": {
+				Type:        schema.TypeBool,
+				Description: "Whether the key existed before it was managed",
+				Computed:    true,
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemINIValueCreate,
+		Read:   resourceFilesystemINIValueRead,
+		Update: resourceFilesystemINIValueUpdate,
+		Delete: resourceFilesystemINIValueDelete,
+	}
+}
+
+func resourceFilesystemINIValueCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_ini_value", "create", d)
+	log.Debug("calling resourceFilesystemINIValueCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	section, key := d.Get("section").(string), d.Get("key").(string)
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		previous, exists := iniGet(content, section, key)
+		d.Set("previous_value", previous)
+		d.Set("previous_value_exists", exists)
+
+		return iniSet(content, section, key, d.Get("value").(string)), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(fmt.Sprintf("%s:[%s]%s", d.Get("path"), section, key))
+
+	log.Info("set key %q in section [%s]", key, section)
+
+	return nil
+}
+
+func resourceFilesystemINIValueRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_ini_value", "read", d)
+	log.Debug("calling resourceFilesystemINIValueRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	value, ok := iniGet(content, d.Get("section").(string), d.Get("key").(string))
+	if !ok {
+		d.SetId("")
+		return nil
+	}
+	d.Set("value", value)
+
+	return nil
+}
+
+func resourceFilesystemINIValueUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_ini_value", "update", d)
+	log.Debug("calling resourceFilesystemINIValueUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if d.HasChange("value") {
+		section, key := d.Get("section").(string), d.Get("key").(string)
+
+		err := p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+			return iniSet(content, section, key, d.Get("value").(string)), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Info("updated key %q in section [%s]", key, section)
+	}
+
+	return nil
+}
+
+func resourceFilesystemINIValueDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_ini_value", "delete", d)
+	log.Debug("calling resourceFilesystemINIValueDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	section, key := d.Get("section").(string), d.Get("key").(string)
+	restore := d.Get("restore_on_destroy").(bool) && d.Get("previous_value_exists").(bool)
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		if restore {
+			return iniSet(content, section, key, d.Get("previous_value").(string)), nil
+		}
+		return iniDelete(content, section, key), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if restore {
+		log.Info("restored key %q in section [%s]", key, section)
+	} else {
+		log.Info("removed key %q from section [%s]", key, section)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const testINIValueFileContent = `; managed by the vendor
+[mysqld]
+port = 3306
+
+[client]
+port = 3306
+`
+
+func TestAccFilesystemINIValue(t *testing.T) {
+	const (
+		iniValueCreateResource = `
+resource "filesystem_ini_value" "port" {
+  path = "/tmp/testfile.ini"
+  section = "mysqld"
+  key = "port"
+  value = "3307"
+  restore_on_destroy = true
+}
+
+resource "filesystem_ini_value" "bind_address" {
+  path = "/tmp/testfile.ini"
+  section = "mysqld"
+  key = "bind-address"
+  value = "127.0.0.1"
+}
+
+resource "filesystem_ini_value" "quick" {
+  path = "/tmp/testfile.ini"
+  section = "mysqldump"
+  key = "quick"
+  value = "1"
+}
+`
+
+		iniValueUpdateResource = `
+resource "filesystem_ini_value" "port" {
+  path = "/tmp/testfile.ini"
+  section = "mysqld"
+  key = "port"
+  value = "3308"
+  restore_on_destroy = true
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { ioutil.WriteFile("/tmp/testfile.ini", []byte(testINIValueFileContent), 0644) },
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemINIValueContent(
+						"; managed by the vendor\n[mysqld]\nport = 3307\nbind-address = 127.0.0.1\n\n[client]\nport = 3306\n\n[mysqldump]\nquick = 1\n"),
+					resource.TestCheckResourceAttr("filesystem_ini_value.port", "previous_value", "3306"),
+					resource.TestCheckResourceAttr("filesystem_ini_value.port", "previous_value_exists", "true"),
+					resource.TestCheckResourceAttr("filesystem_ini_value.quick", "previous_value_exists", "false"),
+				),
+				Config: iniValueCreateResource,
+			},
+			resource.TestStep{
+				// Destroyed values are removed, the sections being left
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemINIValueContent(
+					"; managed by the vendor\n[mysqld]\nport = 3308\n\n[client]\nport = 3306\n\n[mysqldump]\n")),
+				Config: iniValueUpdateResource,
+			},
+			resource.TestStep{
+				// A port changed by hand is set back
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.ini", []byte("[mysqld]\nport = 4242\n"), 0644)
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemINIValueContent("[mysqld]\nport = 3308\n")),
+				Config: iniValueUpdateResource,
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(testFilesystemINIValueContent("[mysqld]\nport = 3306\n")),
+	})
+
+	os.Remove("/tmp/testfile.ini")
+}
+
+func testFilesystemINIValueContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.ini")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,338 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceJSONPatch() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the JSON file, created if needed",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"values": {
+				Type:             schema.TypeMap,
+				Description:      "JSON values to set, by JSON pointer (e.g. /log-opts/max-size)",
+				Optional:         true,
+				ForceNew:         false,
+				ConflictsWith:    []string{"merge_patch"},
+				ValidateFunc:     validateJSONPatchValues,
+				DiffSuppressFunc: suppressEquivalentJSON,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+			},
+			"merge_patch": {
+				Type:             schema.TypeString,
+				Description:      "JSON merge patch (RFC 7386) to apply",
+				Optional:         true,
+				ForceNew:         false,
+				ValidateFunc:     validateJSON,
+				DiffSuppressFunc: suppressEquivalentJSON,
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemJSONPatchCreate,
+		Read:   resourceFilesystemJSONPatchRead,
+		Update: resourceFilesystemJSONPatchUpdate,
+		Delete: resourceFilesystemJSONPatchDelete,
+	}
+}
+
+func resourceFilesystemJSONPatchCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_json_patch", "create", d)
+	log.Debug("calling resourceFilesystemJSONPatchCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := p.editFile(path, p.defaultFileMode, jsonPatchEdit(d, nil)); err != nil {
+		return err
+	}
+
+	d.SetId(jsonPatchID(d))
+
+	log.Info("patched JSON document")
+
+	return nil
+}
+
+func resourceFilesystemJSONPatchRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_json_patch", "read", d)
+	log.Debug("calling resourceFilesystemJSONPatchRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	document, err := decodeOrderedJSON(content)
+	if err != nil {
+		return err
+	}
+
+	// The document is read back through the configured pointers only (and the merge patch projection
+	// below), so that the keys owned by other tools never show up in plans
+	values := map[string]interface{}{}
+	for pointer := range d.Get("values").(map[string]interface{}) {
+		tokens, _ := parseJSONPointer(pointer)
+		if value, ok := jsonPointerGet(document, tokens); ok {
+			if values[pointer], err = encodeOrderedJSON(value, ""); err != nil {
+				return err
+			}
+		}
+	}
+	d.Set("values", values)
+
+	if patch := d.Get("merge_patch").(string); patch != "" {
+		patchValue, err := decodeOrderedJSON(patch)
+		if err != nil {
+			return err
+		}
+
+		projection, err := encodeOrderedJSON(mergePatchProjection(document, patchValue), "")
+		if err != nil {
+			return err
+		}
+		d.Set("merge_patch", projection)
+	}
+
+	return nil
+}
+
+func resourceFilesystemJSONPatchUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_json_patch", "update", d)
+	log.Debug("calling resourceFilesystemJSONPatchUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	// Pointers dropped from the configuration and keys only set by the previous merge patch are
+	// removed in the same edit that applies the new values
+	oldValues, newValues := d.GetChange("values")
+	oldPatch, newPatch := d.GetChange("merge_patch")
+
+	var removedPointers []string
+	for pointer := range oldValues.(map[string]interface{}) {
+		if _, ok := newValues.(map[string]interface{})[pointer]; !ok {
+			removedPointers = append(removedPointers, pointer)
+		}
+	}
+
+	removedPatch, err := mergePatchDifference(oldPatch.(string), newPatch.(string))
+	if err != nil {
+		return err
+	}
+
+	if err := p.editFile(path, p.defaultFileMode, jsonPatchEdit(d, jsonPatchRemove(removedPointers, removedPatch))); err != nil {
+		return err
+	}
+
+	log.Info("patched JSON document")
+
+	return nil
+}
+
+func resourceFilesystemJSONPatchDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_json_patch", "delete", d)
+	log.Debug("calling resourceFilesystemJSONPatchDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	var pointers []string
+	for pointer := range d.Get("values").(map[string]interface{}) {
+		pointers = append(pointers, pointer)
+	}
+
+	patch, err := mergePatchDifference(d.Get("merge_patch").(string), "")
+	if err != nil {
+		return err
+	}
+
+	remove := jsonPatchRemove(pointers, patch)
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		if content == "" {
+			return content, nil
+		}
+		return editJSON(content, remove)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("removed patched values from JSON document")
+
+	return nil
+}
+
+// jsonPatchEdit returns the edit of the JSON file applying the values or merge patch of a
+// filesystem_json_patch resource, after removing previously managed values with remove, if set
+func jsonPatchEdit(d *schema.ResourceData, remove func(interface{}) (interface{}, error)) func(string) (string, error) {
+	return func(content string) (string, error) {
+		return editJSON(content, func(document interface{}) (interface{}, error) {
+			var err error
+			if remove != nil {
+				if document, err = remove(document); err != nil {
+					return nil, err
+				}
+			}
+
+			values := d.Get("values").(map[string]interface{})
+			for _, pointer := range sortedKeys(values) {
+				value, err := decodeOrderedJSON(values[pointer].(string))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", pointer, err)
+				}
+
+				tokens, err := parseJSONPointer(pointer)
+				if err != nil {
+					return nil, err
+				}
+
+				if document, err = jsonPointerSet(document, tokens, value); err != nil {
+					return nil, fmt.Errorf("unable to set %s: %s", pointer, err)
+				}
+			}
+
+			if patch := d.Get("merge_patch").(string); patch != "" {
+				patchValue, err := decodeOrderedJSON(patch)
+				if err != nil {
+					return nil, err
+				}
+				document = mergePatch(document, patchValue)
+			}
+
+			return document, nil
+		})
+	}
+}
+
+// jsonPatchID returns the ID of a filesystem_json_patch resource: its path followed by the sorted
+// pointers of the values it manages, so that resources patching the same document have distinct IDs.
+// The ID is only set on create, to remain stable when the managed values change
+func jsonPatchID(d *schema.ResourceData) string {
+	pointers := sortedKeys(d.Get("values").(map[string]interface{}))
+	if patch := d.Get("merge_patch").(string); patch != "" {
+		if patchValue, err := decodeOrderedJSON(patch); err == nil {
+			pointers = mergePatchPointers(patchValue, "")
+			sort.Strings(pointers)
+		}
+	}
+
+	return fmt.Sprintf("%s:%s", d.Get("path"), strings.Join(pointers, ","))
+}
+
+// jsonPatchRemove returns the edit of a JSON document removing the values referenced by the given
+// pointers and the keys set by the given merge patch (if not nil)
+func jsonPatchRemove(pointers []string, patch interface{}) func(interface{}) (interface{}, error) {
+	return func(document interface{}) (interface{}, error) {
+		paths := make([][]string, len(pointers))
+		for i, pointer := range pointers {
+			tokens, err := parseJSONPointer(pointer)
+			if err != nil {
+				return nil, err
+			}
+			paths[i] = tokens
+		}
+
+		// Values are removed in reverse order, for the indexes of the array elements still to be
+		// removed to remain valid
+		sort.Slice(paths, func(i, j int) bool { return lessTokens(paths[j], paths[i]) })
+		for _, tokens := range paths {
+			document, _ = jsonPointerRemove(document, tokens)
+		}
+
+		if patch != nil {
+			document = mergePatchRemove(document, patch)
+		}
+
+		return document, nil
+	}
+}
+
+// mergePatchDifference returns the JSON merge patch setting the keys set by the old merge patch but
+// not by the new one (nil if none)
+func mergePatchDifference(oldPatch, newPatch string) (interface{}, error) {
+	if oldPatch == "" {
+		return nil, nil
+	}
+
+	oldValue, err := decodeOrderedJSON(oldPatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var newValue interface{}
+	if newPatch != "" {
+		if newValue, err = decodeOrderedJSON(newPatch); err != nil {
+			return nil, err
+		}
+	}
+
+	return mergePatchExclude(oldValue, newValue), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateJSONPatchValues(i interface{}, k string) (ws []string, errors []error) {
+	for pointer, value := range i.(map[string]interface{}) {
+		if _, err := parseJSONPointer(pointer); err != nil {
+			errors = append(errors, fmt.Errorf("%q: %s", k, err))
+		}
+
+		if value, ok := value.(string); ok {
+			if _, err := decodeOrderedJSON(value); err != nil {
+				errors = append(errors, fmt.Errorf("%q: %s: %s", k, pointer, err))
+			}
+		}
+	}
+	return
+}
+
+func suppressEquivalentJSON(k, old, new string, d *schema.ResourceData) bool {
+	return old != "" && new != "" && equivalentJSON(old, new)
+}
@@ -0,0 +1,95 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccFilesystemJSONPatch(t *testing.T) {
+	const (
+		jsonPatchCreateResource = `
+resource "filesystem_json_patch" "test" {
+  path = "/tmp/testfile.json"
+  values = {
+    "/log-level" = "\"warn\""
+    "/log-opts/max-size" = "\"10m\""
+  }
+}
+`
+
+		jsonPatchUpdateResource = `
+resource "filesystem_json_patch" "test" {
+  path = "/tmp/testfile.json"
+  merge_patch = "{\"features\": {\"buildkit\": true}, \"debug\": null}"
+}
+`
+
+		// Semantically equal to the previous merge patch: no diff
+		jsonPatchUpdateEquivalentResource = `
+resource "filesystem_json_patch" "test" {
+  path = "/tmp/testfile.json"
+  merge_patch = "{\"debug\":null,\"features\":{\"buildkit\":true}}"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.json", []byte("{\n    \"debug\": false,\n    \"log-opts\": {\"max-file\": \"3\"}\n}\n"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemJSONPatchContent("{\n    \"debug\": false,\n    \"log-opts\": {\n        \"max-file\": \"3\",\n        \"max-size\": \"10m\"\n    },\n    \"log-level\": \"warn\"\n}\n"),
+					resource.TestCheckResourceAttr("filesystem_json_patch.test", "id", "/tmp/testfile.json:/log-level,/log-opts/max-size"),
+				),
+				Config: jsonPatchCreateResource,
+			},
+			resource.TestStep{
+				// The log level changed by hand is set back, while the added debug key is kept
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.json", []byte("{\"debug\": true, \"log-opts\": {\"max-file\": \"3\", \"max-size\": \"10m\"}, \"log-level\": \"debug\"}\n"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemJSONPatchContent(
+					"{\n  \"debug\": true,\n  \"log-opts\": {\n    \"max-file\": \"3\",\n    \"max-size\": \"10m\"\n  },\n  \"log-level\": \"warn\"\n}\n")),
+				Config: jsonPatchCreateResource,
+			},
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemJSONPatchContent("{\n  \"log-opts\": {\n    \"max-file\": \"3\"\n  },\n  \"features\": {\n    \"buildkit\": true\n  }\n}\n"),
+					resource.TestCheckResourceAttr("filesystem_json_patch.test", "id", "/tmp/testfile.json:/log-level,/log-opts/max-size"),
+				),
+				Config: jsonPatchUpdateResource,
+			},
+			resource.TestStep{
+				PlanOnly: true,
+				Config:   jsonPatchUpdateEquivalentResource,
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(testFilesystemJSONPatchContent(
+			"{\n  \"log-opts\": {\n    \"max-file\": \"3\"\n  }\n}\n")),
+	})
+
+	os.Remove("/tmp/testfile.json")
+}
+
+func testFilesystemJSONPatchContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.json")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
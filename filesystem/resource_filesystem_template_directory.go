@@ -0,0 +1,507 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/facette/logger"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceTemplateDirectory() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the directory to render the templates into",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"source_dir": {
+				Type:        schema.TypeString,
+				Description: "Path to the directory of the templates (*.tmpl files) and files to copy verbatim",
+				Required:    true,
+				ForceNew:    false,
+			},
+			"vars": {
+				Type:        schema.TypeMap,
+				Description: "Variables to render the templates with",
+				Optional:    true,
+				ForceNew:    false,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"user": {
+				Type:        schema.TypeString,
+				Description: "Files and directories owner user name (default: provider default_user or current user)",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    false,
+			},
+			"group": {
+				Type:        schema.TypeString,
+				Description: "Files and directories owner group name (default: provider default_group or current user group)",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    false,
+			},
+			"file_mode": {
+				Type:         schema.TypeString,
+				Description:  "Permissions to apply to files (in octal representation, e.g. 0644)",
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     false,
+				ValidateFunc: validateMode,
+			},
+			"dir_mode": {
+				Type:         schema.TypeString,
+				Description:  "Permissions to apply to directories (in octal representation, e.g. 0755)",
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     false,
+				ValidateFunc: validateMode,
+			},
+			"override": {
+				Type:        schema.TypeList,
+				Description: "Owner and permissions of specific files",
+				Optional:    true,
+				ForceNew:    false,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:        schema.TypeString,
+							Description: "Glob pattern of the destination file paths, relative to the directory",
+							Required:    true,
+						},
+						"user": {
+							Type:        schema.TypeString,
+							Description: "File owner user name",
+							Optional:    true,
+						},
+						"group": {
+							Type:        schema.TypeString,
+							Description: "File owner group name",
+							Optional:    true,
+						},
+						"mode": {
+							Type:         schema.TypeString,
+							Description:  "Permissions to apply to file (in octal representation, e.g. 0600)",
+							Optional:     true,
+							ValidateFunc: validateMode,
+						},
+					},
+				},
+			},
+			"manifest": {
+				Type:        schema.TypeMap,
+				Description: "Content digests of the managed files, by path relative to the directory",
+				Computed:    true,
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemTemplateDirectoryCreate,
+		Read:   resourceFilesystemTemplateDirectoryRead,
+		Update: resourceFilesystemTemplateDirectoryUpdate,
+		Delete: resourceFilesystemTemplateDirectoryDelete,
+	}
+}
+
+func resourceFilesystemTemplateDirectoryCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_template_directory", "create", d)
+	log.Debug("calling resourceFilesystemTemplateDirectoryCreate()")
+
+	if d.Get("file_mode").(string) == "" {
+		d.Set("file_mode", fmt.Sprintf("%#o", p.defaultFileMode))
+	}
+
+	if d.Get("dir_mode").(string) == "" {
+		d.Set("dir_mode", fmt.Sprintf("%#o", p.defaultDirMode))
+	}
+
+	if err := p.setDefaultOwner(d); err != nil {
+		return err
+	}
+
+	if err := p.syncTemplateDirectory(d, log); err != nil {
+		return err
+	}
+
+	d.SetId(d.Get("path").(string))
+
+	log.Info("rendered %d files (owner %s:%s)", len(d.Get("manifest").(map[string]interface{})), d.Get("user"), d.Get("group"))
+
+	return nil
+}
+
+func resourceFilesystemTemplateDirectoryRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_template_directory", "read", d)
+	log.Debug("calling resourceFilesystemTemplateDirectoryRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	// Files missing from the destination are dropped from the manifest, to be rendered again
+	manifest := map[string]interface{}{}
+	for file := range d.Get("manifest").(map[string]interface{}) {
+		filePath, err := p.resolvePath(filepath.Join(d.Get("path").(string), file))
+		if err != nil {
+			return err
+		}
+
+		sha, err := p.hashFile(filePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		manifest[file] = contentDigestPrefix + sha
+	}
+	d.Set("manifest", manifest)
+
+	return nil
+}
+
+func resourceFilesystemTemplateDirectoryUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_template_directory", "update", d)
+	log.Debug("calling resourceFilesystemTemplateDirectoryUpdate()")
+
+	return p.syncTemplateDirectory(d, log)
+}
+
+func resourceFilesystemTemplateDirectoryDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_template_directory", "delete", d)
+	log.Debug("calling resourceFilesystemTemplateDirectoryDelete()")
+
+	for file := range d.Get("manifest").(map[string]interface{}) {
+		if err := p.removeTemplateDirectoryFile(d.Get("path").(string), file); err != nil {
+			return err
+		}
+
+		log.Info("removed file %s", file)
+	}
+
+	if err := p.removeEmptyDir(d.Get("path").(string)); err != nil {
+		return err
+	}
+
+	log.Info("removed directory")
+
+	return nil
+}
+
+// renderTemplateDirectory renders the templates of the source directory (*.tmpl files, rendered
+// into files without the .tmpl extension) and reads the other files, returning the content of the
+// destination files by relative path
+func renderTemplateDirectory(ctx context.Context, sourceDir string, vars map[string]interface{}) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	err := filepath.Walk(sourceDir, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+
+		if !fileInfo.Mode().IsRegular() {
+			return nil
+		}
+
+		file, _ := filepath.Rel(sourceDir, path)
+		file = filepath.ToSlash(file)
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(file, ".tmpl") {
+			rendered, err := renderTemplate(path, string(content), vars)
+			if err != nil {
+				return err
+			}
+			file, content = strings.TrimSuffix(file, ".tmpl"), []byte(rendered)
+		}
+
+		if _, ok := files[file]; ok {
+			return fmt.Errorf("both %q and %q.tmpl render to %q", file, file, file)
+		}
+		files[file] = content
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to render templates: %s", err)
+	}
+
+	return files, nil
+}
+
+// templateDirectoryManifest returns the content digests of the destination files by relative path
+func templateDirectoryManifest(files map[string][]byte) map[string]string {
+	manifest := map[string]string{}
+	for file, content := range files {
+		manifest[file] = contentDigest(string(content))
+	}
+	return manifest
+}
+
+// syncTemplateDirectory renders the templates into the destination directory, applies owners and
+// permissions, and removes the files previously rendered which are no longer in the source
+// directory
+func (p filesystemProvider) syncTemplateDirectory(d *schema.ResourceData, log *logger.Logger) error {
+	dir := d.Get("path").(string)
+
+	files, err := renderTemplateDirectory(p.ctx, d.Get("source_dir").(string), d.Get("vars").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+
+	uid, err := p.accounts.lookupUser(d.Get("user").(string))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner user information: %s", err)
+	}
+
+	gid, err := p.accounts.lookupGroup(d.Get("group").(string))
+	if err != nil {
+		return fmt.Errorf("unable to lookup file owner group information: %s", err)
+	}
+
+	fileMode, _ := strconv.ParseUint(d.Get("file_mode").(string), 8, 32)
+	dirMode, _ := strconv.ParseUint(d.Get("dir_mode").(string), 8, 32)
+
+	names := make([]string, 0, len(files))
+	for file := range files {
+		names = append(names, file)
+	}
+	sort.Strings(names)
+
+	for _, file := range append([]string{"."}, names...) {
+		// Parent directories are created as needed, the destination directory included
+		for _, parent := range parentDirs(file) {
+			parentPath, err := p.resolvePath(filepath.Join(dir, parent))
+			if err != nil {
+				return err
+			}
+
+			if _, err := os.Stat(parentPath); !os.IsNotExist(err) {
+				continue
+			}
+
+			if err := p.mkdir(parentPath, os.FileMode(dirMode), true); err != nil {
+				return err
+			}
+
+			if err := p.chown(parentPath, uid, gid); err != nil {
+				return fmt.Errorf("unable to change directory user/group: %s", err)
+			}
+
+			log.Info("created directory %s", parent)
+		}
+	}
+
+	for _, file := range names {
+		filePath, err := p.resolvePath(filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+
+		fileUID, fileGID, mode, err := p.templateDirectoryOverride(d, file, uid, gid, os.FileMode(fileMode))
+		if err != nil {
+			return err
+		}
+
+		changed, err := p.syncFile(filePath, files[file], mode, fileUID, fileGID)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			log.Info("rendered file %s (%d bytes)", file, len(files[file]))
+		}
+	}
+
+	// Files rendered previously, but no longer part of the source directory
+	oldManifest, _ := d.GetChange("manifest")
+	for file := range oldManifest.(map[string]interface{}) {
+		if _, ok := files[file]; ok {
+			continue
+		}
+
+		if err := p.removeTemplateDirectoryFile(dir, file); err != nil {
+			return err
+		}
+
+		log.Info("removed file %s", file)
+	}
+
+	manifest := map[string]interface{}{}
+	for file, digest := range templateDirectoryManifest(files) {
+		manifest[file] = digest
+	}
+	d.Set("manifest", manifest)
+
+	return nil
+}
+
+// templateDirectoryOverride returns the owner and permissions of a destination file, as overridden
+// by the first override matching the file relative path
+func (p filesystemProvider) templateDirectoryOverride(d *schema.ResourceData, file string, uid, gid int, mode os.FileMode) (int, int, os.FileMode, error) {
+	for _, o := range d.Get("override").([]interface{}) {
+		override := o.(map[string]interface{})
+
+		if matched, err := filepath.Match(override["path"].(string), file); err != nil || !matched {
+			continue
+		}
+
+		var err error
+		if username := override["user"].(string); username != "" {
+			if uid, err = p.accounts.lookupUser(username); err != nil {
+				return 0, 0, 0, fmt.Errorf("unable to lookup file owner user information: %s", err)
+			}
+		}
+
+		if groupname := override["group"].(string); groupname != "" {
+			if gid, err = p.accounts.lookupGroup(groupname); err != nil {
+				return 0, 0, 0, fmt.Errorf("unable to lookup file owner group information: %s", err)
+			}
+		}
+
+		if overrideMode := override["mode"].(string); overrideMode != "" {
+			fileMode, _ := strconv.ParseUint(overrideMode, 8, 32)
+			mode = os.FileMode(fileMode)
+		}
+
+		break
+	}
+
+	return uid, gid, mode, nil
+}
+
+// syncFile writes content to the file at path and applies owner and permissions, only performing
+// the changes needed, and reports whether the content has been written
+func (p filesystemProvider) syncFile(path string, content []byte, mode os.FileMode, uid, gid int) (bool, error) {
+	changed := true
+	if sha, err := p.hashFile(path); err == nil {
+		changed = sha != hash(string(content))
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if changed {
+		if err := p.writeFile(path, content, mode, nil); err != nil {
+			return false, err
+		}
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	if fileInfo.Mode() != mode {
+		if err := p.chmod(path, mode); err != nil {
+			return false, err
+		}
+	}
+
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	if int(stat.Uid) != uid || int(stat.Gid) != gid {
+		if err := p.chown(path, uid, gid); err != nil {
+			return false, fmt.Errorf("unable to change file user/group: %s", err)
+		}
+	}
+
+	return changed, nil
+}
+
+// removeTemplateDirectoryFile removes a destination file, along with its parent directories left
+// empty (the destination directory excluded)
+func (p filesystemProvider) removeTemplateDirectoryFile(dir, file string) error {
+	filePath, err := p.resolvePath(filepath.Join(dir, file))
+	if err != nil {
+		return err
+	}
+
+	if err := p.remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	parents := parentDirs(file)
+	for i := len(parents) - 1; i > 0; i-- {
+		if err := p.removeEmptyDir(filepath.Join(dir, parents[i])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeEmptyDir removes the directory at path if it exists and is empty
+func (p filesystemProvider) removeEmptyDir(path string) error {
+	dirPath, err := p.resolvePath(path)
+	if err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if len(entries) > 0 {
+		return nil
+	}
+
+	return p.remove(dirPath)
+}
+
+// parentDirs returns the parent directories of a relative path, from the outermost one (".")
+func parentDirs(file string) []string {
+	if file == "." {
+		return []string{"."}
+	}
+
+	dirs := []string{"."}
+	for i, c := range file {
+		if c == '/' {
+			dirs = append(dirs, file[:i])
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,134 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccFilesystemTemplateDirectory(t *testing.T) {
+	const (
+		templateDirectoryResource = `
+resource "filesystem_template_directory" "test" {
+  path = "/tmp/testtemplatedir"
+  source_dir = "/tmp/testtemplates"
+  file_mode = "0640"
+
+  vars {
+    name = "test"
+    password = "s3cr3t"
+  }
+
+  override {
+    path = "secret.conf"
+    mode = "0600"
+  }
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() {
+					os.RemoveAll("/tmp/testtemplates")
+					os.MkdirAll("/tmp/testtemplates/static", 0755)
+					ioutil.WriteFile("/tmp/testtemplates/app.conf.tmpl", []byte("name = {{.name}}\n"), 0644)
+					ioutil.WriteFile("/tmp/testtemplates/secret.conf.tmpl", []byte("password = {{.password}}\n"), 0644)
+					ioutil.WriteFile("/tmp/testtemplates/static/logo.txt", []byte("{{.name}}\n"), 0644)
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemTemplateDirectoryCreate),
+				Config: templateDirectoryResource,
+			},
+			resource.TestStep{
+				// Source changes are detected at plan time
+				PreConfig: func() {
+					os.RemoveAll("/tmp/testtemplates/static")
+					ioutil.WriteFile("/tmp/testtemplates/app.conf.tmpl", []byte("name = {{.name}}\nenabled = true\n"), 0644)
+				},
+				Check:  resource.ComposeAggregateTestCheckFunc(testFilesystemTemplateDirectoryUpdate),
+				Config: templateDirectoryResource,
+			},
+		},
+		CheckDestroy: testFilesystemTemplateDirectoryDelete,
+	})
+}
+
+func testFilesystemTemplateDirectoryCreate(state *terraform.State) error {
+	rs, ok := state.RootModule().Resources["filesystem_template_directory.test"]
+	if !ok {
+		return fmt.Errorf("Not found: %s", "filesystem_template_directory.test")
+	}
+
+	files := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{"/tmp/testtemplatedir/app.conf", "name = test\n", 0640},
+		{"/tmp/testtemplatedir/secret.conf", "password = s3cr3t\n", 0600},
+		{"/tmp/testtemplatedir/static/logo.txt", "{{.name}}\n", 0640},
+	}
+
+	for _, file := range files {
+		fileInfo, err := os.Stat(file.path)
+		if err != nil {
+			return err
+		}
+
+		if fileInfo.Mode() != file.mode {
+			return fmt.Errorf("test file %s mode (%s) different from expected mode (%s)", file.path, fileInfo.Mode(), file.mode)
+		}
+
+		content, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			return err
+		}
+
+		if string(content) != file.content {
+			return fmt.Errorf("test file %s content (%q) different from expected content (%q)", file.path, content, file.content)
+		}
+	}
+
+	if rs.Primary.Attributes["manifest.%"] != "3" {
+		return fmt.Errorf("test template directory manifest size (%s) different from expected size (%d)", rs.Primary.Attributes["manifest.%"], 3)
+	}
+
+	if rs.Primary.Attributes["manifest.static/logo.txt"] != contentDigest("{{.name}}\n") {
+		return fmt.Errorf("test template directory manifest entry (%q) different from expected entry (%q)",
+			rs.Primary.Attributes["manifest.static/logo.txt"],
+			contentDigest("{{.name}}\n"))
+	}
+
+	return nil
+}
+
+func testFilesystemTemplateDirectoryUpdate(state *terraform.State) error {
+	content, err := ioutil.ReadFile("/tmp/testtemplatedir/app.conf")
+	if err != nil {
+		return err
+	}
+
+	if string(content) != "name = test\nenabled = true\n" {
+		return fmt.Errorf("test file content (%q) different from expected content (%q)", content, "name = test\nenabled = true\n")
+	}
+
+	if _, err := os.Stat("/tmp/testtemplatedir/static"); !os.IsNotExist(err) {
+		return fmt.Errorf("test template directory file removed from source not removed from destination")
+	}
+
+	return nil
+}
+
+func testFilesystemTemplateDirectoryDelete(state *terraform.State) error {
+	if _, err := os.Stat("/tmp/testtemplatedir"); !os.IsNotExist(err) {
+		return fmt.Errorf("test template directory not removed")
+	}
+
+	return nil
+}
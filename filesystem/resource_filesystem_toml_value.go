@@ -0,0 +1,245 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func resourceTOMLValue() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the TOML file, created if needed",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"key": {
+				Type:         schema.TypeString,
+				Description:  "Dotted key to set, relative to the root table (e.g. plugins.\"io.containerd.grpc.v1.cri\".sandbox_image)",
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateTOMLKey,
+			},
+			"value": {
+				Type:             schema.TypeString,
+				Description:      "Value of the key, encoded according to its type",
+				Required:         true,
+				ForceNew:         false,
+				DiffSuppressFunc: suppressEquivalentTOMLValues,
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Description: "Type of the value (string, integer, float, boolean, or toml for TOML-encoded values such as arrays)",
+				Optional:    true,
+				Default:     "string",
+				ForceNew:    false,
+				ValidateFunc: func(i interface{}, k string) (ws []string, errors []error) {
+					for _, valueType := range tomlTypes {
+						if i.(string) == valueType {
+							return
+						}
+					}
+					errors = append(errors, fmt.Errorf("%q: must be one of %s", k, strings.Join(tomlTypes, ", ")))
+					return
+				},
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemTOMLValueCreate,
+		Read:   resourceFilesystemTOMLValueRead,
+		Update: resourceFilesystemTOMLValueUpdate,
+		Delete: resourceFilesystemTOMLValueDelete,
+	}
+}
+
+func resourceFilesystemTOMLValueCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_toml_value", "create", d)
+	log.Debug("calling resourceFilesystemTOMLValueCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	key, _, _ := parseTOMLKey(d.Get("key").(string))
+
+	value, err := encodeTOMLValue(d.Get("value").(string), d.Get("type").(string))
+	if err != nil {
+		return err
+	}
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		return tomlSet(content, key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", d.Get("path"), d.Get("key")))
+
+	log.Info("set key %s", d.Get("key"))
+
+	return nil
+}
+
+func resourceFilesystemTOMLValueRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_toml_value", "read", d)
+	log.Debug("calling resourceFilesystemTOMLValueRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	key, _, _ := parseTOMLKey(d.Get("key").(string))
+
+	value, ok, err := tomlGet(content, key)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		d.SetId("")
+		return nil
+	}
+
+	// Values of another type than configured are read along with their type, for Terraform to plan
+	// setting them again
+	if d.Get("type").(string) != "toml" {
+		decoded, valueType, err := decodeTOMLTypedValue(value)
+		if err != nil {
+			return err
+		}
+		value = decoded
+		d.Set("type", valueType)
+	}
+	d.Set("value", value)
+
+	return nil
+}
+
+func resourceFilesystemTOMLValueUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_toml_value", "update", d)
+	log.Debug("calling resourceFilesystemTOMLValueUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if d.HasChange("value") || d.HasChange("type") {
+		key, _, _ := parseTOMLKey(d.Get("key").(string))
+
+		value, err := encodeTOMLValue(d.Get("value").(string), d.Get("type").(string))
+		if err != nil {
+			return err
+		}
+
+		err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+			return tomlSet(content, key, value)
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Info("updated key %s", d.Get("key"))
+	}
+
+	return nil
+}
+
+func resourceFilesystemTOMLValueDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_toml_value", "delete", d)
+	log.Debug("calling resourceFilesystemTOMLValueDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	key, _, _ := parseTOMLKey(d.Get("key").(string))
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		return tomlDelete(content, key)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("removed key %s", d.Get("key"))
+
+	return nil
+}
+
+func validateTOMLKey(i interface{}, k string) (ws []string, errors []error) {
+	key := i.(string)
+	if _, end, err := parseTOMLKey(key); err != nil || end != len(key) {
+		errors = append(errors, fmt.Errorf("%q: invalid TOML key", k))
+	}
+	return
+}
+
+// validateTOMLTypedValue checks that the value of a filesystem_toml_value resource configuration is
+// of its type, when known
+func validateTOMLTypedValue(c *terraform.ResourceConfig) error {
+	if c.IsComputed("value") || c.IsComputed("type") {
+		return nil
+	}
+
+	value, _ := c.Get("value")
+	valueType, ok := c.Get("type")
+	if !ok {
+		valueType = "string"
+	}
+
+	if value, ok := value.(string); ok {
+		if _, err := encodeTOMLValue(value, valueType.(string)); err != nil {
+			return fmt.Errorf("value: %s", err)
+		}
+	}
+	return nil
+}
+
+func suppressEquivalentTOMLValues(k, old, new string, d *schema.ResourceData) bool {
+	valueType := d.Get("type").(string)
+
+	oldValue, err := encodeTOMLValue(old, valueType)
+	if err != nil {
+		return false
+	}
+
+	newValue, err := encodeTOMLValue(new, valueType)
+	if err != nil {
+		return false
+	}
+
+	return equivalentTOMLValues(oldValue, newValue)
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccFilesystemTOMLValue(t *testing.T) {
+	const (
+		tomlValueCreateResource = `
+resource "filesystem_toml_value" "sandbox_image" {
+  path = "/tmp/testfile.toml"
+  key = "plugins.\"io.containerd.grpc.v1.cri\".sandbox_image"
+  value = "registry.k8s.io/pause:3.9"
+}
+
+resource "filesystem_toml_value" "max_concurrent_downloads" {
+  path = "/tmp/testfile.toml"
+  key = "plugins.\"io.containerd.grpc.v1.cri\".max_concurrent_downloads"
+  value = "3"
+  type = "integer"
+}
+`
+
+		// Semantically equal to the previous value: no diff
+		tomlValueUpdateEquivalentResource = `
+resource "filesystem_toml_value" "sandbox_image" {
+  path = "/tmp/testfile.toml"
+  key = "plugins.\"io.containerd.grpc.v1.cri\".sandbox_image"
+  value = "registry.k8s.io/pause:3.9"
+}
+
+resource "filesystem_toml_value" "max_concurrent_downloads" {
+  path = "/tmp/testfile.toml"
+  key = "plugins.\"io.containerd.grpc.v1.cri\".max_concurrent_downloads"
+  value = "+3"
+  type = "integer"
+}
+`
+
+		tomlValueCreateInvalidResource = `
+resource "filesystem_toml_value" "test" {
+  path = "/tmp/testfile.toml"
+  key = "a"
+  value = "[1,"
+  type = "toml"
+}
+`
+
+		tomlValueCreateInvalidTypeResource = `
+resource "filesystem_toml_value" "test" {
+  path = "/tmp/testfile.toml"
+  key = "a"
+  value = "three"
+  type = "integer"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config:      tomlValueCreateInvalidResource,
+				ExpectError: regexp.MustCompile(`invalid TOML value`),
+			},
+			resource.TestStep{
+				Config:      tomlValueCreateInvalidTypeResource,
+				ExpectError: regexp.MustCompile(`invalid integer value`),
+			},
+			resource.TestStep{
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.toml", []byte("version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = \"registry.k8s.io/pause:3.8\" # pinned\n"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemTOMLValueContent(
+					"version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = \"registry.k8s.io/pause:3.9\" # pinned\n  max_concurrent_downloads = 3\n")),
+				Config: tomlValueCreateResource,
+			},
+			resource.TestStep{
+				PlanOnly: true,
+				Config:   tomlValueUpdateEquivalentResource,
+			},
+			resource.TestStep{
+				// The value quoted by hand is of another type than configured
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.toml", []byte("version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = 'registry.k8s.io/pause:3.9' # pinned\n  max_concurrent_downloads = \"3\"\n"), 0644)
+				},
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+				Config:             tomlValueCreateResource,
+			},
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemTOMLValueContent(
+					"version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = 'registry.k8s.io/pause:3.9' # pinned\n  max_concurrent_downloads = 3\n")),
+				Config: tomlValueCreateResource,
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(testFilesystemTOMLValueContent(
+			"version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n")),
+	})
+
+	os.Remove("/tmp/testfile.toml")
+}
+
+func testFilesystemTOMLValueContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.toml")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
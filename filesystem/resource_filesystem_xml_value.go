@@ -0,0 +1,229 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceXMLValue() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the XML file",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"xpath": {
+				Type:         schema.TypeString,
+				Description:  "XPath expression selecting the element whose text is set, or the attribute to set (e.g. /Server/Service[@name='Catalina']/Connector[@port='8080']/@connectionTimeout)",
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateXPath,
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Description: "Text or attribute value to set",
+				Required:    true,
+				ForceNew:    false,
+			},
+			"create": {
+				Type:        schema.TypeBool,
+				Description: "Create the selected element or attribute if missing, along with its missing ancestors",
+				Optional:    true,
+				Default:     false,
+				ForceNew:    false,
+			},
+			"restore_on_destroy": {
+				Type:        schema.TypeBool,
+				Description: "Restore the previous value of the node on destroy, instead of removing the node",
+				Optional:    true,
+				Default:     false,
+				ForceNew:    false,
+			},
+			"previous_value": {
+				Type:        schema.TypeString,
+				Description: "Value of the node before it was managed",
+				Computed:    true,
+			},
+			"previous_value_exists": {
+				Type:        schema.TypeBool,
+				Description: "Whether the node existed before it was managed",
+				Computed:    true,
+			},
+			"created_elements": {
+				Type:        schema.TypeInt,
+				Description: "Number of elements created along with the node, the selected element and its ancestors",
+				Computed:    true,
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemXMLValueCreate,
+		Read:   resourceFilesystemXMLValueRead,
+		Update: resourceFilesystemXMLValueUpdate,
+		Delete: resourceFilesystemXMLValueDelete,
+	}
+}
+
+func resourceFilesystemXMLValueCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_xml_value", "create", d)
+	log.Debug("calling resourceFilesystemXMLValueCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	xpath, _ := parseXPath(d.Get("xpath").(string))
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		previous, exists, err := xmlGet(content, xpath)
+		if err != nil {
+			return "", err
+		}
+		d.Set("previous_value", previous)
+		d.Set("previous_value_exists", exists)
+
+		created := 0
+		if d.Get("create").(bool) {
+			if created, err = xmlMissingSteps(content, xpath); err != nil {
+				return "", err
+			}
+		}
+		d.Set("created_elements", created)
+
+		return xmlSet(content, xpath, d.Get("value").(string), d.Get("create").(bool))
+	})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", d.Get("path"), d.Get("xpath")))
+
+	log.Info("set %s", d.Get("xpath"))
+
+	return nil
+}
+
+func resourceFilesystemXMLValueRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_xml_value", "read", d)
+	log.Debug("calling resourceFilesystemXMLValueRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	xpath, _ := parseXPath(d.Get("xpath").(string))
+
+	value, ok, err := xmlGet(content, xpath)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		d.SetId("")
+		return nil
+	}
+	d.Set("value", value)
+
+	return nil
+}
+
+func resourceFilesystemXMLValueUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_xml_value", "update", d)
+	log.Debug("calling resourceFilesystemXMLValueUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if d.HasChange("value") {
+		xpath, _ := parseXPath(d.Get("xpath").(string))
+
+		err := p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+			return xmlSet(content, xpath, d.Get("value").(string), d.Get("create").(bool))
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Info("updated %s", d.Get("xpath"))
+	}
+
+	return nil
+}
+
+func resourceFilesystemXMLValueDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_xml_value", "delete", d)
+	log.Debug("calling resourceFilesystemXMLValueDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	xpath, _ := parseXPath(d.Get("xpath").(string))
+	restore := d.Get("restore_on_destroy").(bool) && d.Get("previous_value_exists").(bool)
+
+	// The node is removed along with the elements created for it, unless they hold other nodes
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		if _, ok, err := xmlGet(content, xpath); err != nil || !ok {
+			return content, err
+		}
+
+		if restore {
+			return xmlSet(content, xpath, d.Get("previous_value").(string), false)
+		}
+
+		content, err := xmlDelete(content, xpath)
+		if err != nil {
+			return "", err
+		}
+		return xmlDeleteCreated(content, xpath, d.Get("created_elements").(int))
+	})
+	if err != nil {
+		return err
+	}
+
+	if restore {
+		log.Info("restored %s", d.Get("xpath"))
+	} else {
+		log.Info("removed %s", d.Get("xpath"))
+	}
+
+	return nil
+}
+
+func validateXPath(i interface{}, k string) (ws []string, errors []error) {
+	if _, err := parseXPath(i.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
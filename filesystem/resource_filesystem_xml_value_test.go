@@ -0,0 +1,101 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const testXMLValueFileContent = `<?xml version="1.0" encoding="UTF-8"?>
+<Server port="8005" shutdown="SHUTDOWN">
+  <Service name="Catalina">
+    <Connector port="8080" protocol="HTTP/1.1" connectionTimeout="20000"/>
+  </Service>
+</Server>
+`
+
+func TestAccFilesystemXMLValue(t *testing.T) {
+	const (
+		xmlValueCreateResource = `
+resource "filesystem_xml_value" "connection_timeout" {
+  path = "/tmp/testfile.xml"
+  xpath = "/Server/Service[@name='Catalina']/Connector[@port='8080']/@connectionTimeout"
+  value = "30000"
+}
+
+resource "filesystem_xml_value" "ssl_connector" {
+  path = "/tmp/testfile.xml"
+  xpath = "/Server/Service[@name='Catalina']/Connector[@port='8443']/@SSLEnabled"
+  value = "true"
+  create = true
+}
+`
+
+		xmlValueUpdateResource = `
+resource "filesystem_xml_value" "connection_timeout" {
+  path = "/tmp/testfile.xml"
+  xpath = "/Server/Service[@name='Catalina']/Connector[@port='8080']/@connectionTimeout"
+  value = "40000"
+  restore_on_destroy = true
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() { ioutil.WriteFile("/tmp/testfile.xml", []byte(testXMLValueFileContent), 0644) },
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemXMLValueContent(
+						"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Server port=\"8005\" shutdown=\"SHUTDOWN\">\n  <Service name=\"Catalina\">\n    <Connector port=\"8080\" protocol=\"HTTP/1.1\" connectionTimeout=\"30000\"/>\n    <Connector port=\"8443\" SSLEnabled=\"true\"/>\n  </Service>\n</Server>\n"),
+					resource.TestCheckResourceAttr("filesystem_xml_value.connection_timeout", "previous_value", "20000"),
+					resource.TestCheckResourceAttr("filesystem_xml_value.connection_timeout", "previous_value_exists", "true"),
+					resource.TestCheckResourceAttr("filesystem_xml_value.connection_timeout", "created_elements", "0"),
+					resource.TestCheckResourceAttr("filesystem_xml_value.ssl_connector", "previous_value_exists", "false"),
+					resource.TestCheckResourceAttr("filesystem_xml_value.ssl_connector", "created_elements", "1"),
+				),
+				Config: xmlValueCreateResource,
+			},
+			resource.TestStep{
+				// Created attributes are removed on destroy, along with the elements created for them
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemXMLValueContent(
+					"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Server port=\"8005\" shutdown=\"SHUTDOWN\">\n  <Service name=\"Catalina\">\n    <Connector port=\"8080\" protocol=\"HTTP/1.1\" connectionTimeout=\"40000\"/>\n  </Service>\n</Server>\n")),
+				Config: xmlValueUpdateResource,
+			},
+			resource.TestStep{
+				// A timeout changed by hand is set back, the unmanaged port being left as is
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.xml", []byte("<Server><Service name=\"Catalina\"><Connector port=\"8080\" connectionTimeout='1'/></Service></Server>"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemXMLValueContent(
+					"<Server><Service name=\"Catalina\"><Connector port=\"8080\" connectionTimeout='40000'/></Service></Server>")),
+				Config: xmlValueUpdateResource,
+			},
+		},
+		// The previous timeout is restored on destroy
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(testFilesystemXMLValueContent(
+			"<Server><Service name=\"Catalina\"><Connector port=\"8080\" connectionTimeout='20000'/></Service></Server>")),
+	})
+
+	os.Remove("/tmp/testfile.xml")
+}
+
+func testFilesystemXMLValueContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.xml")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
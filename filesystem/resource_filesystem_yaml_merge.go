@@ -0,0 +1,335 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func resourceYAMLMerge() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path to the YAML file, created if needed",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"values": {
+				Type:             schema.TypeMap,
+				Description:      "YAML values to set, by dotted path (e.g. spec.replicas)",
+				Optional:         true,
+				ForceNew:         false,
+				ConflictsWith:    []string{"merge"},
+				ValidateFunc:     validateYAMLMergeValues,
+				DiffSuppressFunc: suppressEquivalentYAML,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+			},
+			"merge": {
+				Type:             schema.TypeString,
+				Description:      "YAML mapping to deep-merge into the document",
+				Optional:         true,
+				ForceNew:         false,
+				ValidateFunc:     validateYAMLMapping,
+				DiffSuppressFunc: suppressEquivalentYAML,
+			},
+		},
+
+		Timeouts: resourceTimeouts(),
+
+		Create: resourceFilesystemYAMLMergeCreate,
+		Read:   resourceFilesystemYAMLMergeRead,
+		Update: resourceFilesystemYAMLMergeUpdate,
+		Delete: resourceFilesystemYAMLMergeDelete,
+	}
+}
+
+func resourceFilesystemYAMLMergeCreate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutCreate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_yaml_merge", "create", d)
+	log.Debug("calling resourceFilesystemYAMLMergeCreate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := p.editFile(path, p.defaultFileMode, yamlMergeEdit(d, nil, nil)); err != nil {
+		return err
+	}
+
+	d.SetId(yamlMergeID(d))
+
+	log.Info("merged YAML document")
+
+	return nil
+}
+
+func resourceFilesystemYAMLMergeRead(d *schema.ResourceData, meta interface{}) error {
+	p := meta.(filesystemProvider)
+
+	log := p.resourceLogger("filesystem_yaml_merge", "read", d)
+	log.Debug("calling resourceFilesystemYAMLMergeRead()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	content, err := p.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			d.SetId("")
+			return nil
+		}
+
+		return err
+	}
+
+	document, err := decodeYAMLNode(content)
+	if err != nil {
+		return err
+	}
+
+	// Managed paths missing from the document are left out of the values, for Terraform to plan
+	// setting them again, other paths being ignored
+	values := map[string]interface{}{}
+	for path := range d.Get("values").(map[string]interface{}) {
+		parts, _ := parseYAMLPath(path)
+		if node, ok := yamlPathGet(document, parts); ok {
+			if values[path], err = encodeYAMLNode(node); err != nil {
+				return err
+			}
+		}
+	}
+	d.Set("values", values)
+
+	if merge := d.Get("merge").(string); merge != "" {
+		mergeNode, err := decodeYAMLNode(merge)
+		if err != nil {
+			return err
+		}
+
+		projection, err := encodeYAMLNode(yamlMergeProjection(document, mergeNode))
+		if err != nil {
+			return err
+		}
+		d.Set("merge", projection)
+	}
+
+	return nil
+}
+
+func resourceFilesystemYAMLMergeUpdate(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_yaml_merge", "update", d)
+	log.Debug("calling resourceFilesystemYAMLMergeUpdate()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	// Paths dropped from the configuration, and keys set by the previous merge only, are removed
+	// before the new values are merged
+	oldValues, newValues := d.GetChange("values")
+	oldMerge, newMerge := d.GetChange("merge")
+
+	var removedPaths []string
+	for path := range oldValues.(map[string]interface{}) {
+		if _, ok := newValues.(map[string]interface{})[path]; !ok {
+			removedPaths = append(removedPaths, path)
+		}
+	}
+
+	removedMerge, err := yamlMergeDifference(oldMerge.(string), newMerge.(string))
+	if err != nil {
+		return err
+	}
+
+	if err := p.editFile(path, p.defaultFileMode, yamlMergeEdit(d, removedPaths, removedMerge)); err != nil {
+		return err
+	}
+
+	log.Info("merged YAML document")
+
+	return nil
+}
+
+func resourceFilesystemYAMLMergeDelete(d *schema.ResourceData, meta interface{}) error {
+	p, cancel := meta.(filesystemProvider).withTimeout(d, schema.TimeoutDelete)
+	defer cancel()
+
+	log := p.resourceLogger("filesystem_yaml_merge", "delete", d)
+	log.Debug("calling resourceFilesystemYAMLMergeDelete()")
+
+	path, err := p.resolvePath(d.Get("path").(string))
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path := range d.Get("values").(map[string]interface{}) {
+		paths = append(paths, path)
+	}
+
+	merge, err := yamlMergeDifference(d.Get("merge").(string), "")
+	if err != nil {
+		return err
+	}
+
+	err = p.editFile(path, p.defaultFileMode, func(content string) (string, error) {
+		if content == "" {
+			return content, nil
+		}
+		return editYAML(content, func(document *yaml.Node) (*yaml.Node, error) {
+			return yamlMergeRemoveAll(document, paths, merge)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("removed merged values from YAML document")
+
+	return nil
+}
+
+// yamlMergeEdit returns the edit of the YAML file setting the values or merging the mapping of a
+// filesystem_yaml_merge resource, after removing the previously managed paths and merged keys
+func yamlMergeEdit(d *schema.ResourceData, removedPaths []string, removedMerge *yaml.Node) func(string) (string, error) {
+	return func(content string) (string, error) {
+		return editYAML(content, func(document *yaml.Node) (*yaml.Node, error) {
+			document, err := yamlMergeRemoveAll(document, removedPaths, removedMerge)
+			if err != nil {
+				return nil, err
+			}
+
+			values := d.Get("values").(map[string]interface{})
+			for _, path := range sortedKeys(values) {
+				value, err := decodeYAMLNode(values[path].(string))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", path, err)
+				}
+
+				parts, err := parseYAMLPath(path)
+				if err != nil {
+					return nil, err
+				}
+
+				if document, err = yamlPathSet(document, parts, value); err != nil {
+					return nil, fmt.Errorf("unable to set %s: %s", path, err)
+				}
+			}
+
+			if merge := d.Get("merge").(string); merge != "" {
+				mergeNode, err := decodeYAMLNode(merge)
+				if err != nil {
+					return nil, err
+				}
+				document = yamlMerge(document, mergeNode)
+			}
+
+			return document, nil
+		})
+	}
+}
+
+// yamlMergeID returns the ID of a filesystem_yaml_merge resource, made of its path and of the
+// sorted paths it manages (those of the leaves of merged mappings) when it is created: the ID is
+// kept when the managed paths change
+func yamlMergeID(d *schema.ResourceData) string {
+	paths := sortedKeys(d.Get("values").(map[string]interface{}))
+	if merge := d.Get("merge").(string); merge != "" {
+		if mergeNode, err := decodeYAMLNode(merge); err == nil {
+			paths = yamlMergePaths(mergeNode, "")
+			sort.Strings(paths)
+		}
+	}
+
+	return fmt.Sprintf("%s:%s", d.Get("path"), strings.Join(paths, ","))
+}
+
+// yamlMergeRemoveAll removes the values at the given paths and the keys set by the merge of the
+// given mapping (if not nil) from a document
+func yamlMergeRemoveAll(document *yaml.Node, paths []string, merge *yaml.Node) (*yaml.Node, error) {
+	partsList := make([][]string, len(paths))
+	for i, path := range paths {
+		parts, err := parseYAMLPath(path)
+		if err != nil {
+			return nil, err
+		}
+		partsList[i] = parts
+	}
+
+	// Values are removed in reverse order, for the indexes of the sequence items still to be removed
+	// to remain valid
+	sort.Slice(partsList, func(i, j int) bool { return lessTokens(partsList[j], partsList[i]) })
+	for _, parts := range partsList {
+		yamlPathRemove(document, parts)
+	}
+
+	if merge != nil {
+		yamlMergeRemove(document, merge)
+	}
+
+	return document, nil
+}
+
+// yamlMergeDifference returns the mapping setting the keys set by the old merged mapping but not
+// by the new one (nil if none)
+func yamlMergeDifference(oldMerge, newMerge string) (*yaml.Node, error) {
+	if oldMerge == "" {
+		return nil, nil
+	}
+
+	oldNode, err := decodeYAMLNode(oldMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	var newNode *yaml.Node
+	if newMerge != "" {
+		if newNode, err = decodeYAMLNode(newMerge); err != nil {
+			return nil, err
+		}
+	}
+
+	return yamlMergeExclude(oldNode, newNode), nil
+}
+
+func validateYAMLMergeValues(i interface{}, k string) (ws []string, errors []error) {
+	for path, value := range i.(map[string]interface{}) {
+		if _, err := parseYAMLPath(path); err != nil {
+			errors = append(errors, fmt.Errorf("%q: %s", k, err))
+		}
+
+		if value, ok := value.(string); ok {
+			if _, err := decodeYAMLNode(value); err != nil {
+				errors = append(errors, fmt.Errorf("%q: %s: %s", k, path, err))
+			}
+		}
+	}
+	return
+}
+
+func validateYAMLMapping(i interface{}, k string) (ws []string, errors []error) {
+	if node, err := decodeYAMLNode(i.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	} else if node.Kind != yaml.MappingNode {
+		errors = append(errors, fmt.Errorf("%q: not a YAML mapping", k))
+	}
+	return
+}
+
+func suppressEquivalentYAML(k, old, new string, d *schema.ResourceData) bool {
+	return old != "" && new != "" && equivalentYAML(old, new)
+}
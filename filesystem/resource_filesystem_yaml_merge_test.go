@@ -0,0 +1,99 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccFilesystemYAMLMerge(t *testing.T) {
+	const (
+		yamlMergeCreateResource = `
+resource "filesystem_yaml_merge" "test" {
+  path = "/tmp/testfile.yml"
+  values = {
+    "all.vars.ntp_server" = "ntp.example.com"
+    "all.hosts.web1" = "{}"
+  }
+}
+`
+
+		yamlMergeUpdateResource = `
+resource "filesystem_yaml_merge" "test" {
+  path = "/tmp/testfile.yml"
+  merge = <<EOF
+all:
+  vars:
+    ansible_user: deploy
+EOF
+}
+`
+
+		// Semantically equal to the previous mapping: no diff
+		yamlMergeUpdateEquivalentResource = `
+resource "filesystem_yaml_merge" "test" {
+  path = "/tmp/testfile.yml"
+  merge = "{all: {vars: {ansible_user: 'deploy'}}}"
+}
+`
+	)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]terraform.ResourceProvider{"filesystem": Provider()},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.yml", []byte("# inventory\nall:\n  vars:\n    ntp_server: pool.ntp.org # default\n"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemYAMLMergeContent("# inventory\nall:\n  vars:\n    ntp_server: ntp.example.com # default\n  hosts:\n    web1: {}\n"),
+					resource.TestCheckResourceAttr("filesystem_yaml_merge.test", "id", "/tmp/testfile.yml:all.hosts.web1,all.vars.ntp_server"),
+				),
+				Config: yamlMergeCreateResource,
+			},
+			resource.TestStep{
+				// The NTP server changed by hand is set back, while the added timezone is kept
+				PreConfig: func() {
+					ioutil.WriteFile("/tmp/testfile.yml", []byte("# inventory\nall:\n  vars:\n    ntp_server: pool.ntp.org\n    timezone: UTC\n  hosts:\n    web1: {}\n"), 0644)
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(testFilesystemYAMLMergeContent(
+					"# inventory\nall:\n  vars:\n    ntp_server: ntp.example.com\n    timezone: UTC\n  hosts:\n    web1: {}\n")),
+				Config: yamlMergeCreateResource,
+			},
+			resource.TestStep{
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testFilesystemYAMLMergeContent("# inventory\nall:\n  vars:\n    timezone: UTC\n    ansible_user: deploy\n"),
+					resource.TestCheckResourceAttr("filesystem_yaml_merge.test", "id", "/tmp/testfile.yml:all.hosts.web1,all.vars.ntp_server"),
+				),
+				Config: yamlMergeUpdateResource,
+			},
+			resource.TestStep{
+				PlanOnly: true,
+				Config:   yamlMergeUpdateEquivalentResource,
+			},
+		},
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(testFilesystemYAMLMergeContent(
+			"# inventory\nall:\n  vars:\n    timezone: UTC\n")),
+	})
+
+	os.Remove("/tmp/testfile.yml")
+}
+
+func testFilesystemYAMLMergeContent(expected string) resource.TestCheckFunc {
+	return func(state *terraform.State) error {
+		content, err := ioutil.ReadFile("/tmp/testfile.yml")
+		if err != nil {
+			return err
+		}
+
+		if string(content) != expected {
+			return fmt.Errorf("test file content (%q) different from expected content (%q)", content, expected)
+		}
+
+		return nil
+	}
+}
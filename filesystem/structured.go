@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// canonicalJSON returns the canonical form of a JSON document: object keys sorted, indented with
+// two spaces and followed by a newline
+func canonicalJSON(s string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(s))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("invalid JSON: %s", err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return "", fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	var canonical bytes.Buffer
+	encoder := json.NewEncoder(&canonical)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+
+	return canonical.String(), nil
+}
+
+// canonicalYAML returns the canonical form of a YAML stream: mapping keys sorted, indented with two
+// spaces, comments and formatting discarded
+func canonicalYAML(s string) (string, error) {
+	var canonical bytes.Buffer
+	encoder := yaml.NewEncoder(&canonical)
+	encoder.SetIndent(2)
+
+	for decoder := yaml.NewDecoder(bytes.NewBufferString(s)); ; {
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("invalid YAML: %s", err)
+		}
+
+		if err := encoder.Encode(value); err != nil {
+			return "", err
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return canonical.String(), nil
+}
+
+func validateJSON(i interface{}, k string) (ws []string, errors []error) {
+	if _, err := canonicalJSON(i.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
+
+func validateYAML(i interface{}, k string) (ws []string, errors []error) {
+	if _, err := canonicalYAML(i.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// renderTemplate renders a Go text/template with the given variables (referencing undefined
+// variables being an error), error messages referring to the template by name and line
+func renderTemplate(name, text string, vars map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
+
+// diffTemplate renders the template of a filesystem_file resource, if any, into the content
+// attribute diff, so that the content digest is known at plan time. The content is unknown until
+// apply if the template or its variables are.
+func diffTemplate(r *schema.Resource, name string, s *terraform.InstanceState, diff *terraform.InstanceDiff) error {
+	var oldContent string
+	if s != nil {
+		oldContent = s.Attributes["content"]
+	}
+
+	for k, attrDiff := range diff.Attributes {
+		if (k == "template" || k == "template_source" || strings.HasPrefix(k, "vars.")) && attrDiff.NewComputed {
+			diff.Attributes["content"] = &terraform.ResourceAttrDiff{Old: oldContent, NewComputed: true}
+			return nil
+		}
+	}
+
+	d := r.Data(s.MergeDiff(diff))
+
+	text := d.Get("template").(string)
+	if source := d.Get("template_source").(string); source != "" {
+		content, err := ioutil.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("unable to read template: %s", err)
+		}
+		text, name = string(content), source
+	} else if text == "" {
+		return nil
+	}
+
+	rendered, err := renderTemplate(name, text, d.Get("vars").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+
+	diff.Attributes["content"] = &terraform.ResourceAttrDiff{
+		Old:      oldContent,
+		New:      contentDigest(rendered),
+		NewExtra: rendered,
+	}
+
+	return nil
+}
+
+// diffTemplateDirectory renders the templates of a filesystem_template_directory resource into the
+// manifest attribute diff, so that changes of the rendered files are known at plan time. The
+// manifest is unknown until apply if the source directory or the template variables are.
+func diffTemplateDirectory(ctx context.Context, r *schema.Resource, s *terraform.InstanceState, diff *terraform.InstanceDiff) error {
+	oldManifest := map[string]string{}
+	if s != nil && !diff.RequiresNew() {
+		for k, v := range s.Attributes {
+			if strings.HasPrefix(k, "manifest.") && k != "manifest.%" {
+				oldManifest[strings.TrimPrefix(k, "manifest.")] = v
+			}
+		}
+	}
+
+	for k := range diff.Attributes {
+		if strings.HasPrefix(k, "manifest.") {
+			delete(diff.Attributes, k)
+		}
+	}
+
+	for k, attrDiff := range diff.Attributes {
+		if (k == "source_dir" || strings.HasPrefix(k, "vars.")) && attrDiff.NewComputed {
+			diff.Attributes["manifest.%"] = &terraform.ResourceAttrDiff{Old: strconv.Itoa(len(oldManifest)), NewComputed: true}
+			return nil
+		}
+	}
+
+	d := r.Data(s.MergeDiff(diff))
+
+	files, err := renderTemplateDirectory(ctx, d.Get("source_dir").(string), d.Get("vars").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	manifest := templateDirectoryManifest(files)
+
+	for file, digest := range manifest {
+		if oldManifest[file] != digest {
+			diff.Attributes["manifest."+file] = &terraform.ResourceAttrDiff{Old: oldManifest[file], New: digest}
+		}
+	}
+
+	for file, digest := range oldManifest {
+		if _, ok := manifest[file]; !ok {
+			diff.Attributes["manifest."+file] = &terraform.ResourceAttrDiff{Old: digest, NewRemoved: true}
+		}
+	}
+
+	if len(manifest) != len(oldManifest) {
+		diff.Attributes["manifest.%"] = &terraform.ResourceAttrDiff{Old: strconv.Itoa(len(oldManifest)), New: strconv.Itoa(len(manifest))}
+	}
+
+	return nil
+}
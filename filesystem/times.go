@@ -0,0 +1,106 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func validateTimestamp(i interface{}, k string) (ws []string, errors []error) {
+	if _, err := time.Parse(time.RFC3339, i.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: invalid RFC3339 timestamp: %s", k, err))
+	}
+	return
+}
+
+// suppressEquivalentTimestamps prevents spurious diffs between timestamps referring to the same
+// instant expressed in different time zones (e.g. `2018-02-23T01:00:00+01:00` and `2018-02-23T00:00:00Z`)
+func suppressEquivalentTimestamps(k, old, new string, d *schema.ResourceData) bool {
+	oldTime, err := time.Parse(time.RFC3339, old)
+	if err != nil {
+		return false
+	}
+
+	newTime, err := time.Parse(time.RFC3339, new)
+	if err != nil {
+		return false
+	}
+
+	return oldTime.Equal(newTime)
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// setTimes applies the `mtime` and `atime` attributes to the file at path if they are set, keeping
+// the current value of the attribute left unset, and refreshes both attributes
+func (p filesystemProvider) setTimes(d *schema.ResourceData, path string) error {
+	mtimeValue := d.Get("mtime").(string)
+	atimeValue := d.Get("atime").(string)
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if mtimeValue != "" || atimeValue != "" {
+		mtime := fileInfo.ModTime()
+		if mtimeValue != "" {
+			mtime, _ = time.Parse(time.RFC3339, mtimeValue)
+		}
+
+		atime := fileAccessTime(fileInfo)
+		if atimeValue != "" {
+			atime, _ = time.Parse(time.RFC3339, atimeValue)
+		}
+
+		if err := p.chtimes(path, atime, mtime); err != nil {
+			return fmt.Errorf("unable to change file access/modification times: %s", err)
+		}
+
+		if fileInfo, err = os.Stat(path); err != nil {
+			return err
+		}
+	}
+
+	readTimes(d, fileInfo)
+
+	return nil
+}
+
+// readTimes refreshes the `mtime` and `atime` attributes from the file information. Both attributes
+// are computed, so that timestamps left unset in the configuration are exported without being
+// reported as drift.
+func readTimes(d *schema.ResourceData, fileInfo os.FileInfo) {
+	d.Set("mtime", formatTimestamp(fileInfo.ModTime()))
+	d.Set("atime", formatTimestamp(fileAccessTime(fileInfo)))
+}
+
+// diffTimes marks the `mtime` and `atime` attributes left unset in the configuration as computed
+// when the file content changes, since writing the file updates its times
+func diffTimes(s *terraform.InstanceState, c *terraform.ResourceConfig, diff *terraform.InstanceDiff) {
+	if s == nil || s.ID == "" {
+		return
+	}
+
+	changed := false
+	for _, attribute := range append([]string{"content"}, digestContentAttributes...) {
+		if _, ok := diff.Attributes[attribute]; ok {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	for _, attribute := range []string{"mtime", "atime"} {
+		if _, ok := c.Get(attribute); !ok {
+			diff.Attributes[attribute] = &terraform.ResourceAttrDiff{Old: s.Attributes[attribute], NewComputed: true}
+		}
+	}
+}
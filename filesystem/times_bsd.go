@@ -0,0 +1,20 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func fileAccessTime(fileInfo os.FileInfo) time.Time {
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	return time.Unix(int64(stat.Atimespec.Sec), int64(stat.Atimespec.Nsec))
+}
+
+// openNoatime opens the file for reading (O_NOATIME is not supported on this platform)
+func openNoatime(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,22 @@
+package filesystem
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func fileAccessTime(fileInfo os.FileInfo) time.Time {
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	return time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+}
+
+// openNoatime opens the file for reading without updating its access time when permitted
+// (O_NOATIME requires to be the file owner or to have the CAP_FOWNER capability)
+func openNoatime(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOATIME, 0)
+	if err != nil && os.IsPermission(err) {
+		return os.Open(path)
+	}
+	return file, err
+}
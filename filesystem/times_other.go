@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+// +build !linux,!darwin,!freebsd,!netbsd
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func fileAccessTime(fileInfo os.FileInfo) time.Time {
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	return time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+}
+
+// openNoatime opens the file for reading (O_NOATIME is not supported on this platform)
+func openNoatime(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,496 @@
+package filesystem
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The following functions edit TOML files line by line, so that comments and formatting are
+// preserved. Values are addressed by dotted keys (e.g. plugins."io.containerd.grpc.v1.cri".sandbox_image),
+// relative to the root table. Keys of arrays of tables and inline tables can't be addressed.
+
+// tomlEntry is a key/value pair of a TOML file, whose value spans from line, start to endLine, end
+type tomlEntry struct {
+	path                      []string
+	table                     []string
+	line, start, endLine, end int
+}
+
+// tomlHeader is a table header of a TOML file
+type tomlHeader struct {
+	path  []string
+	line  int
+	array bool
+}
+
+// tomlBareKeyRegexp matches the characters of bare keys
+var tomlBareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+
+// parseTOMLKey parses the dotted key at the beginning of s, and returns its parts along with the
+// offset of the first character following the key and its trailing whitespace
+func parseTOMLKey(s string) ([]string, int, error) {
+	var parts []string
+
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+
+		switch {
+		case i < len(s) && s[i] == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, 0, fmt.Errorf("unterminated quoted key")
+			}
+
+			part, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid quoted key %s", s[i:end+1])
+			}
+			parts, i = append(parts, part), end+1
+
+		case i < len(s) && s[i] == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, 0, fmt.Errorf("unterminated quoted key")
+			}
+			parts, i = append(parts, s[i+1:i+1+end]), i+end+2
+
+		default:
+			bare := tomlBareKeyRegexp.FindString(s[i:])
+			if bare == "" {
+				return nil, 0, fmt.Errorf("invalid key")
+			}
+			parts, i = append(parts, bare), i+len(bare)
+		}
+
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+
+		if i >= len(s) || s[i] != '.' {
+			return parts, i, nil
+		}
+		i++
+	}
+}
+
+// formatTOMLKey formats the parts of a dotted key, quoting them as needed
+func formatTOMLKey(parts []string) string {
+	formatted := make([]string, len(parts))
+	for i, part := range parts {
+		if tomlBareKeyRegexp.FindString(part) == part && part != "" {
+			formatted[i] = part
+		} else {
+			formatted[i] = quoteTOMLString(part)
+		}
+	}
+	return strings.Join(formatted, ".")
+}
+
+// quoteTOMLString quotes s as a TOML basic string
+func quoteTOMLString(s string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteRune(r)
+		case '\b':
+			quoted.WriteString(`\b`)
+		case '\t':
+			quoted.WriteString(`\t`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\f':
+			quoted.WriteString(`\f`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&quoted, `\u%04X`, r)
+			} else {
+				quoted.WriteRune(r)
+			}
+		}
+	}
+
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// tomlValueEnd returns the line and offset of the end of the value starting at line i, offset j,
+// which may span several lines (arrays, inline tables and multi-line strings)
+func tomlValueEnd(lines []string, i, j int) (int, int) {
+	depth := 0
+	for ; i < len(lines); i, j = i+1, 0 {
+		line, end := lines[i], j
+
+	scan:
+		for j < len(line) {
+			switch c := line[j]; {
+			case strings.HasPrefix(line[j:], `"""`) || strings.HasPrefix(line[j:], `'''`):
+				delimiter := line[j : j+3]
+				for j += 3; strings.Index(line[j:], delimiter) < 0; j = 0 {
+					if i+1 >= len(lines) {
+						return i, len(line)
+					}
+					i++
+					line = lines[i]
+				}
+				j += strings.Index(line[j:], delimiter) + 3
+				end = j
+
+			case c == '"' || c == '\'':
+				for j++; j < len(line) && line[j] != c; j++ {
+					if c == '"' && line[j] == '\\' {
+						j++
+					}
+				}
+				j++
+				end = j
+
+			case c == '#':
+				break scan
+
+			case c == '[' || c == '{':
+				depth++
+				j++
+				end = j
+
+			case c == ']' || c == '}':
+				depth--
+				j++
+				end = j
+
+			case c == ' ' || c == '\t':
+				j++
+
+			default:
+				j++
+				end = j
+			}
+		}
+
+		if depth <= 0 {
+			if end > len(line) {
+				end = len(line)
+			}
+			return i, end
+		}
+	}
+
+	return len(lines) - 1, len(lines[len(lines)-1])
+}
+
+// parseTOMLLines returns the key/value pairs and table headers of the lines of a TOML file
+func parseTOMLLines(lines []string) ([]tomlEntry, []tomlHeader, error) {
+	var (
+		entries []tomlEntry
+		headers []tomlHeader
+		table   []string
+		array   bool
+	)
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t")
+		indent := len(lines[i]) - len(line)
+
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+
+		case line[0] == '[':
+			array = strings.HasPrefix(line, "[[")
+
+			offset := 1
+			if array {
+				offset = 2
+			}
+
+			path, end, err := parseTOMLKey(line[offset:])
+			if err != nil || !strings.HasPrefix(line[offset+end:], "]") {
+				return nil, nil, fmt.Errorf("line %d: invalid table header", i+1)
+			}
+
+			table = path
+			headers = append(headers, tomlHeader{path: path, line: i, array: array})
+
+		default:
+			key, end, err := parseTOMLKey(line)
+			if err != nil || !strings.HasPrefix(line[end:], "=") {
+				return nil, nil, fmt.Errorf("line %d: invalid key/value pair", i+1)
+			}
+
+			start := indent + end + 1
+			for start < len(lines[i]) && (lines[i][start] == ' ' || lines[i][start] == '\t') {
+				start++
+			}
+
+			endLine, endOffset := tomlValueEnd(lines, i, start)
+			if !array {
+				entries = append(entries, tomlEntry{
+					path:    append(append([]string{}, table...), key...),
+					table:   table,
+					line:    i,
+					start:   start,
+					endLine: endLine,
+					end:     endOffset,
+				})
+			}
+			i = endLine
+		}
+	}
+
+	return entries, headers, nil
+}
+
+func equalTOMLKeys(a, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// tomlFind returns the key/value pair of the given dotted key, if any
+func tomlFind(entries []tomlEntry, path []string) (tomlEntry, bool) {
+	for _, entry := range entries {
+		if equalTOMLKeys(entry.path, path) {
+			return entry, true
+		}
+	}
+	return tomlEntry{}, false
+}
+
+// tomlGet returns the value of the given dotted key as written in the file, and whether it exists
+func tomlGet(content string, path []string) (string, bool, error) {
+	lines, _ := splitLines(content)
+
+	entries, _, err := parseTOMLLines(lines)
+	if err != nil {
+		return "", false, err
+	}
+
+	entry, ok := tomlFind(entries, path)
+	if !ok {
+		return "", false, nil
+	}
+
+	if entry.endLine == entry.line {
+		return lines[entry.line][entry.start:entry.end], true, nil
+	}
+
+	value := []string{lines[entry.line][entry.start:]}
+	value = append(value, lines[entry.line+1:entry.endLine]...)
+	return strings.Join(append(value, lines[entry.endLine][:entry.end]), "\n"), true, nil
+}
+
+// tomlSet sets the value of the given dotted key. Missing keys are added after the last key of
+// their table, the table being added at the end of the file if missing. The edited content must
+// be valid TOML holding the value.
+func tomlSet(content string, path []string, value string) (string, error) {
+	lines, newline := splitLines(content)
+
+	entries, headers, err := parseTOMLLines(lines)
+	if err != nil {
+		return "", err
+	}
+
+	if entry, ok := tomlFind(entries, path); ok {
+		replaced := lines[entry.line][:entry.start] + value + lines[entry.endLine][entry.end:]
+		lines = append(lines[:entry.line], append([]string{replaced}, lines[entry.endLine+1:]...)...)
+		return checkTOMLValue(joinLines(lines, newline), path, value)
+	}
+
+	parent := path[:len(path)-1]
+
+	// The key is added after the last key of its table, either defined by a header or by dotted
+	// keys, or after the table header, with the indentation of the previous key
+	insert, table, indent := -1, []string(nil), ""
+	for _, header := range headers {
+		if !header.array && equalTOMLKeys(header.path, parent) {
+			insert, table = header.line, header.path
+		}
+	}
+	for _, entry := range entries {
+		if equalTOMLKeys(entry.path[:len(entry.path)-1], parent) {
+			insert, table = entry.endLine, entry.table
+			indent = lines[entry.line][:len(lines[entry.line])-len(strings.TrimLeft(lines[entry.line], " \t"))]
+		}
+	}
+
+	switch {
+	case insert >= 0:
+		line := indent + formatTOMLKey(path[len(table):]) + " = " + value
+		lines = append(lines[:insert+1], append([]string{line}, lines[insert+1:]...)...)
+
+	case len(parent) == 0:
+		// Root keys are added before the first table header
+		insert = len(lines)
+		if len(headers) > 0 {
+			insert = headers[0].line
+		}
+
+		added := []string{formatTOMLKey(path) + " = " + value}
+		if insert < len(lines) {
+			added = append(added, "")
+		}
+		lines = append(lines[:insert], append(added, lines[insert:]...)...)
+
+	default:
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+formatTOMLKey(parent)+"]", formatTOMLKey(path[len(parent):])+" = "+value)
+	}
+
+	return checkTOMLValue(joinLines(lines, newline), path, value)
+}
+
+// tomlDelete removes the given dotted key
+func tomlDelete(content string, path []string) (string, error) {
+	lines, newline := splitLines(content)
+
+	entries, _, err := parseTOMLLines(lines)
+	if err != nil {
+		return "", err
+	}
+
+	entry, ok := tomlFind(entries, path)
+	if !ok {
+		return content, nil
+	}
+
+	return joinLines(append(lines[:entry.line], lines[entry.endLine+1:]...), newline), nil
+}
+
+// checkTOMLValue checks that content is valid TOML holding value at the given dotted key
+func checkTOMLValue(content string, path []string, value string) (string, error) {
+	var document map[string]interface{}
+	if _, err := toml.Decode(content, &document); err != nil {
+		return "", fmt.Errorf("unable to set %s: %s", formatTOMLKey(path), err)
+	}
+
+	var node interface{} = document
+	for _, part := range path {
+		table, ok := node.(map[string]interface{})
+		if !ok {
+			node = nil
+			break
+		}
+		node = table[part]
+	}
+
+	expected, err := decodeTOMLValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	if !reflect.DeepEqual(node, expected) {
+		return "", fmt.Errorf("unable to set %s: key defined in an array of tables or inline table", formatTOMLKey(path))
+	}
+
+	return content, nil
+}
+
+// decodeTOMLValue decodes a TOML value (e.g. "text", 42, true or [1, 2])
+func decodeTOMLValue(value string) (interface{}, error) {
+	var document map[string]interface{}
+	if _, err := toml.Decode("value = "+value, &document); err != nil {
+		return nil, fmt.Errorf("invalid TOML value %s: %s", value, err)
+	}
+	return document["value"], nil
+}
+
+// tomlTypes are the types of the values set by filesystem_toml_value resources, "toml" values being
+// TOML-encoded (e.g. arrays or dates)
+var tomlTypes = []string{"string", "integer", "float", "boolean", "toml"}
+
+// encodeTOMLValue encodes a value of the given type as a TOML value
+func encodeTOMLValue(value, valueType string) (string, error) {
+	switch valueType {
+	case "string":
+		return quoteTOMLString(value), nil
+
+	case "integer":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid integer value %q", value)
+		}
+		return strconv.FormatInt(i, 10), nil
+
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("invalid float value %q", value)
+		}
+
+		// TOML floats hold a fractional part or an exponent
+		encoded := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(encoded, ".e") {
+			encoded += ".0"
+		}
+		return encoded, nil
+
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid boolean value %q", value)
+		}
+		return strconv.FormatBool(b), nil
+	}
+
+	if _, err := decodeTOMLValue(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// decodeTOMLTypedValue returns the string form of a TOML value along with its type, values of
+// other types than strings, integers, floats and booleans being returned as written with the "toml"
+// type
+func decodeTOMLTypedValue(value string) (string, string, error) {
+	decoded, err := decodeTOMLValue(value)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch v := decoded.(type) {
+	case string:
+		return v, "string", nil
+	case int64:
+		return strconv.FormatInt(v, 10), "integer", nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), "float", nil
+	case bool:
+		return strconv.FormatBool(v), "boolean", nil
+	}
+	return value, "toml", nil
+}
+
+// equivalentTOMLValues reports whether two TOML values are equal
+func equivalentTOMLValues(a, b string) bool {
+	aValue, err := decodeTOMLValue(a)
+	if err != nil {
+		return false
+	}
+
+	bValue, err := decodeTOMLValue(b)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(aValue, bValue)
+}
@@ -0,0 +1,144 @@
+package filesystem
+
+import "testing"
+
+const testTOMLContent = `# containerd configuration
+version = 2
+
+[plugins."io.containerd.grpc.v1.cri"]
+  sandbox_image = "registry.k8s.io/pause:3.8" # pinned
+  registries = [
+    "docker.io", # hub
+    "quay.io",
+  ]
+
+  [plugins."io.containerd.grpc.v1.cri".containerd]
+    snapshotter = 'overlayfs'
+`
+
+func TestTOMLSet(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Key      string
+		Value    string
+		Expected string
+	}{
+		"replaced": {
+			Content:  testTOMLContent,
+			Key:      `plugins."io.containerd.grpc.v1.cri".sandbox_image`,
+			Value:    `"registry.k8s.io/pause:3.9"`,
+			Expected: "# containerd configuration\nversion = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = \"registry.k8s.io/pause:3.9\" # pinned\n  registries = [\n    \"docker.io\", # hub\n    \"quay.io\",\n  ]\n\n  [plugins.\"io.containerd.grpc.v1.cri\".containerd]\n    snapshotter = 'overlayfs'\n",
+		},
+		"replaced multi-line": {
+			Content:  testTOMLContent,
+			Key:      `plugins."io.containerd.grpc.v1.cri".registries`,
+			Value:    `["ghcr.io"]`,
+			Expected: "# containerd configuration\nversion = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = \"registry.k8s.io/pause:3.8\" # pinned\n  registries = [\"ghcr.io\"]\n\n  [plugins.\"io.containerd.grpc.v1.cri\".containerd]\n    snapshotter = 'overlayfs'\n",
+		},
+		"added to table": {
+			Content:  testTOMLContent,
+			Key:      `plugins."io.containerd.grpc.v1.cri".containerd.default_runtime_name`,
+			Value:    `"runc"`,
+			Expected: testTOMLContent + "    default_runtime_name = \"runc\"\n",
+		},
+		"added to root": {
+			Content:  testTOMLContent,
+			Key:      `root`,
+			Value:    `"/var/lib/containerd"`,
+			Expected: "# containerd configuration\nversion = 2\nroot = \"/var/lib/containerd\"\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = \"registry.k8s.io/pause:3.8\" # pinned\n  registries = [\n    \"docker.io\", # hub\n    \"quay.io\",\n  ]\n\n  [plugins.\"io.containerd.grpc.v1.cri\".containerd]\n    snapshotter = 'overlayfs'\n",
+		},
+		"added table": {
+			Content:  testTOMLContent,
+			Key:      `metrics.address`,
+			Value:    `"127.0.0.1:1338"`,
+			Expected: testTOMLContent + "\n[metrics]\naddress = \"127.0.0.1:1338\"\n",
+		},
+		"empty content": {
+			Content:  "",
+			Key:      `a.b`,
+			Value:    `true`,
+			Expected: "[a]\nb = true\n",
+		},
+	}
+
+	for name, tc := range cases {
+		key, _, err := parseTOMLKey(tc.Key)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		content, err := tomlSet(tc.Content, key, tc.Value)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if content != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, content, tc.Expected)
+		}
+
+		if value, ok, _ := tomlGet(content, key); !ok || value != tc.Value {
+			t.Fatalf("%s: value (%q) different from expected value (%q)", name, value, tc.Value)
+		}
+	}
+}
+
+func TestTOMLDelete(t *testing.T) {
+	key, _, _ := parseTOMLKey(`plugins."io.containerd.grpc.v1.cri".registries`)
+
+	content, err := tomlDelete(testTOMLContent, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "# containerd configuration\nversion = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\"]\n  sandbox_image = \"registry.k8s.io/pause:3.8\" # pinned\n\n  [plugins.\"io.containerd.grpc.v1.cri\".containerd]\n    snapshotter = 'overlayfs'\n"
+	if content != expected {
+		t.Fatalf("content (%q) different from expected content (%q)", content, expected)
+	}
+}
+
+func TestEncodeTOMLValue(t *testing.T) {
+	cases := map[string]struct {
+		Value    string
+		Type     string
+		Expected string
+		Error    bool
+	}{
+		"string":             {Value: `C:\temp "x"`, Type: "string", Expected: `"C:\\temp \"x\""`},
+		"control characters": {Value: "a\tb\x01", Type: "string", Expected: `"a\tb\u0001"`},
+		"numeric string":     {Value: "42", Type: "string", Expected: `"42"`},
+		"integer":            {Value: "-42", Type: "integer", Expected: "-42"},
+		"invalid integer":    {Value: "4.2", Type: "integer", Error: true},
+		"float":              {Value: "0.5", Type: "float", Expected: "0.5"},
+		"integral float":     {Value: "3", Type: "float", Expected: "3.0"},
+		"infinite float":     {Value: "inf", Type: "float", Error: true},
+		"boolean":            {Value: "true", Type: "boolean", Expected: "true"},
+		"invalid boolean":    {Value: "yes", Type: "boolean", Error: true},
+		"toml":               {Value: `["a", 1]`, Type: "toml", Expected: `["a", 1]`},
+		"invalid toml":       {Value: "[1,", Type: "toml", Error: true},
+	}
+
+	for name, tc := range cases {
+		encoded, err := encodeTOMLValue(tc.Value, tc.Type)
+		if tc.Error {
+			if err == nil {
+				t.Fatalf("%s: expected error, got value %q", name, encoded)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if encoded != tc.Expected {
+			t.Fatalf("%s: value (%q) different from expected value (%q)", name, encoded, tc.Expected)
+		}
+
+		value, valueType, err := decodeTOMLTypedValue(encoded)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if decoded, _ := encodeTOMLValue(value, valueType); valueType != tc.Type || !equivalentTOMLValues(decoded, encoded) {
+			t.Fatalf("%s: decoded value (%q, %s) different from value (%q, %s)", name, value, valueType, tc.Value, tc.Type)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// transaction snapshots files before they are changed into a journal directory, so that the
+// changes performed by the provider can be rolled back. The snapshots of a provider run are
+// stored in a dedicated sub-directory of the journal directory, created upon first change, the
+// runs preceding it being then removed but the latest retention ones. As the provider isn't
+// notified of the end of an apply, the run directory of a successful run is left in place.
+type transaction struct {
+	journalDir string
+	retention  int
+	runDir     string
+	sequence   int
+	mutex      sync.Mutex
+
+	// Held for reading while snapshotting and performing a change, for writing while rolling back
+	changes sync.RWMutex
+}
+
+func newTransaction(journalDir string, retention int) (*transaction, error) {
+	journalDir, err := filepath.Abs(journalDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve transaction journal directory: %s", err)
+	}
+
+	return &transaction{journalDir: journalDir, retention: retention}, nil
+}
+
+// snapshot describes a file state prior to a change (content being stored in a separate file)
+type snapshot struct {
+	Path    string      `json:"path"`
+	Exists  bool        `json:"exists"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	UID     int         `json:"uid,omitempty"`
+	GID     int         `json:"gid,omitempty"`
+	Atime   time.Time   `json:"atime,omitempty"`
+	Mtime   time.Time   `json:"mtime,omitempty"`
+	Content string      `json:"content,omitempty"`
+}
+
+// transactionScope is the part of a transaction performed by a single resource apply: the changes
+// recorded in a scope are rolled back on their own when the apply fails, the changes of the other
+// resources (already recorded in the Terraform state when successful) being kept
+type transactionScope struct {
+	*transaction
+	entries []string
+	mutex   sync.Mutex
+}
+
+// scope returns a new scope of the transaction
+func (t *transaction) scope() *transactionScope {
+	return &transactionScope{transaction: t}
+}
+
+// snapshot records the current state of the file at path in the journal, the journal entry being
+// recorded in the scope
+func (s *transactionScope) snapshot(ctx context.Context, path string, withContent bool) error {
+	entry, err := s.transaction.snapshot(ctx, path, withContent)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.entries = append(s.entries, entry)
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// rollback restores the snapshots of the scope in reverse order and removes their journal entries.
+// The current run is then closed, so that the changes performed from then on are recorded into a
+// new run.
+func (s *transactionScope) rollback() error {
+	s.changes.Lock()
+	defer s.changes.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.entries) == 0 {
+		return nil
+	}
+
+	if err := restoreEntries(s.entries); err != nil {
+		return err
+	}
+	s.entries = nil
+
+	s.transaction.mutex.Lock()
+	defer s.transaction.mutex.Unlock()
+
+	if s.runDir != "" {
+		// The run directory is only kept if it holds the snapshots of other resources
+		if entries, _ := filepath.Glob(filepath.Join(s.runDir, "*.json")); len(entries) == 0 {
+			os.RemoveAll(s.runDir)
+		}
+	}
+	s.runDir = ""
+	s.sequence = 0
+
+	return nil
+}
+
+// snapshot records the current state of the file at path in the journal, including the file
+// content if requested, and returns the path of its journal entry
+func (t *transaction) snapshot(ctx context.Context, path string, withContent bool) (string, error) {
+	t.mutex.Lock()
+	if t.runDir == "" {
+		t.runDir = filepath.Join(t.journalDir, fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102T150405.000000000Z"), os.Getpid()))
+
+		if err := os.MkdirAll(t.runDir, 0700); err != nil {
+			t.runDir = ""
+			t.mutex.Unlock()
+			return "", fmt.Errorf("unable to create transaction journal directory: %s", err)
+		}
+
+		if err := t.prune(); err != nil {
+			t.mutex.Unlock()
+			return "", fmt.Errorf("unable to remove previous transaction journal runs: %s", err)
+		}
+	}
+	t.sequence++
+	name := filepath.Join(t.runDir, fmt.Sprintf("%08d", t.sequence))
+	t.mutex.Unlock()
+
+	s := snapshot{Path: path}
+
+	fileInfo, err := os.Lstat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err == nil {
+		stat := fileInfo.Sys().(*syscall.Stat_t)
+
+		s.Exists = true
+		s.Mode = fileInfo.Mode()
+		s.UID = int(stat.Uid)
+		s.GID = int(stat.Gid)
+		s.Atime = fileAccessTime(fileInfo)
+		s.Mtime = fileInfo.ModTime()
+
+		if withContent && fileInfo.Mode().IsRegular() {
+			s.Content = filepath.Base(name) + ".data"
+
+			if err := copyFile(ctx, path, name+".data"); err != nil {
+				return "", fmt.Errorf("unable to snapshot file %q content: %s", path, err)
+			}
+		}
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(name+".json", data, 0600); err != nil {
+		return "", fmt.Errorf("unable to write transaction journal entry: %s", err)
+	}
+
+	return name + ".json", nil
+}
+
+// prune removes the runs of the journal directory preceding the current one, but the latest
+// retention ones (runs of concurrent provider instances started later being left in place)
+func (t *transaction) prune() error {
+	runs, err := ioutil.ReadDir(t.journalDir)
+	if err != nil {
+		return err
+	}
+
+	var previous []string
+	for _, run := range runs {
+		if run.IsDir() && !strings.HasPrefix(run.Name(), ".") && run.Name() < filepath.Base(t.runDir) {
+			previous = append(previous, run.Name())
+		}
+	}
+
+	for len(previous) > t.retention {
+		if err := os.RemoveAll(filepath.Join(t.journalDir, previous[0])); err != nil {
+			return err
+		}
+		previous = previous[1:]
+	}
+
+	return nil
+}
+
+// Rollback restores the file snapshots stored in a transaction journal run directory in reverse
+// order, and removes the directory once all of them have been restored.
+func Rollback(runDir string) error {
+	entries, err := filepath.Glob(filepath.Join(runDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	if err := restoreEntries(entries); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(runDir)
+}
+
+// restoreEntries restores the file snapshots of journal entries in reverse order, and removes the
+// entries once all of them have been restored
+func restoreEntries(entries []string) error {
+	entries = append([]string(nil), entries...)
+	sort.Sort(sort.Reverse(sort.StringSlice(entries)))
+
+	var errs *multierror.Error
+
+	for _, entry := range entries {
+		data, err := ioutil.ReadFile(entry)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		var s snapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %s", entry, err))
+			continue
+		}
+
+		if err := s.restore(filepath.Dir(entry)); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("unable to restore %q: %s", s.Path, err))
+		}
+	}
+
+	if err := errs.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		os.Remove(strings.TrimSuffix(entry, ".json") + ".data")
+		if err := os.Remove(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s snapshot) restore(runDir string) error {
+	if !s.Exists {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	// Symbolic links are not managed by the provider
+	if s.Mode&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	if s.Mode.IsDir() {
+		if err := os.Mkdir(s.Path, s.Mode.Perm()); err != nil && !os.IsExist(err) {
+			return err
+		}
+	} else if s.Content != "" {
+		// Restoring must not be interrupted, as it usually follows an interruption
+		if err := copyFile(context.Background(), filepath.Join(runDir, s.Content), s.Path); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Chmod(s.Path, s.Mode); err != nil {
+		return err
+	}
+
+	if err := os.Chown(s.Path, s.UID, s.GID); err != nil {
+		return err
+	}
+
+	return os.Chtimes(s.Path, s.Atime, s.Mtime)
+}
+
+// copyFile copies the content of the file at src to the file at dst, creating it if needed
+func copyFile(ctx context.Context, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := copyContext(ctx, out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// LatestRun returns the most recent run directory of a transaction journal directory.
+func LatestRun(journalDir string) (string, error) {
+	runs, err := ioutil.ReadDir(journalDir)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].IsDir() && !strings.HasPrefix(runs[i].Name(), ".") {
+			return filepath.Join(journalDir, runs[i].Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("no run found in transaction journal directory %q", journalDir)
+}
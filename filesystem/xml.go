@@ -0,0 +1,620 @@
+package filesystem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The following functions edit XML documents by splicing their bytes, so that the XML declaration,
+// comments, formatting and quoting are preserved. Nodes are selected by a subset of XPath: absolute
+// location paths of element names, with attribute value ([@port='8080']) and position ([2])
+// predicates, optionally ending with an attribute (/@port) or text() step. Element and attribute
+// names are matched as written in the document, namespace prefixes included.
+
+// xmlStep is a location step of an XPath expression
+type xmlStep struct {
+	name       string
+	attributes []xmlAttribute
+	position   int
+}
+
+// xmlPath is a parsed XPath expression, selecting the text of an element or one of its attributes
+type xmlPath struct {
+	expression string
+	steps      []xmlStep
+	attribute  string
+}
+
+// xmlAttribute is an attribute of an XML element, whose raw value spans from start to end (quotes
+// excluded), the attribute being preceded by whitespace starting at offset
+type xmlAttribute struct {
+	name, value        string
+	offset, start, end int
+}
+
+// xmlElement is an element of an XML document, whose start tag spans from start to startEnd, and
+// end tag from end to closeEnd (empty for self-closing elements)
+type xmlElement struct {
+	name                           string
+	attributes                     []xmlAttribute
+	children                       []*xmlElement
+	text                           string
+	start, startEnd, end, closeEnd int
+	selfClosing                    bool
+}
+
+// xmlNameRegexp matches the characters of element and attribute names
+var xmlNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.:-]*`)
+
+// parseXPath parses an XPath expression of the supported subset
+func parseXPath(expression string) (xmlPath, error) {
+	path := xmlPath{expression: expression}
+
+	invalid := func(reason string) (xmlPath, error) {
+		return xmlPath{}, fmt.Errorf("invalid XPath %q: %s", expression, reason)
+	}
+
+	if !strings.HasPrefix(expression, "/") || strings.HasPrefix(expression, "//") {
+		return invalid("only absolute location paths are supported")
+	}
+
+	for i := 0; i < len(expression); {
+		if expression[i] != '/' {
+			return invalid(fmt.Sprintf("unexpected %q", expression[i:]))
+		}
+		i++
+
+		if len(path.steps) > 0 {
+			if expression[i:] == "text()" {
+				break
+			}
+
+			if strings.HasPrefix(expression[i:], "@") {
+				name := xmlNameRegexp.FindString(expression[i+1:])
+				if name == "" || i+1+len(name) != len(expression) {
+					return invalid("attribute steps must be the last ones")
+				}
+				path.attribute = name
+				break
+			}
+		}
+
+		name := xmlNameRegexp.FindString(expression[i:])
+		if name == "" {
+			return invalid("missing element name")
+		}
+		step := xmlStep{name: name}
+		i += len(name)
+
+		for i < len(expression) && expression[i] == '[' {
+			end := strings.IndexByte(expression[i:], ']')
+			if end < 0 {
+				return invalid("unterminated predicate")
+			}
+			predicate := strings.TrimSpace(expression[i+1 : i+end])
+
+			if step.position > 0 {
+				return invalid("position predicates must be the last ones of their step")
+			}
+
+			if position, err := strconv.Atoi(predicate); err == nil {
+				if position < 1 {
+					return invalid("positions start at 1")
+				}
+				step.position = position
+				i += end + 1
+				continue
+			}
+
+			// Attribute values may hold a closing bracket
+			match := xmlPredicateRegexp.FindStringSubmatch(expression[i:])
+			if match == nil {
+				return invalid(fmt.Sprintf("unsupported predicate [%s]", predicate))
+			}
+			value := match[2]
+			if value == "" {
+				value = match[3]
+			}
+			step.attributes = append(step.attributes, xmlAttribute{name: match[1], value: value})
+			i += len(match[0])
+		}
+
+		path.steps = append(path.steps, step)
+	}
+
+	return path, nil
+}
+
+// xmlPredicateRegexp matches attribute value predicates
+var xmlPredicateRegexp = regexp.MustCompile(`^\[\s*@([A-Za-z_][A-Za-z0-9_.:-]*)\s*=\s*(?:'([^']*)'|"([^"]*)")\s*\]`)
+
+// matches reports whether an element is selected by the step, its position excluded
+func (s xmlStep) matches(element *xmlElement) bool {
+	if element.name != s.name {
+		return false
+	}
+
+	for _, predicate := range s.attributes {
+		attribute, ok := element.attribute(predicate.name)
+		if !ok || attribute.value != predicate.value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// attribute returns the attribute of the element with the given name, if any
+func (e *xmlElement) attribute(name string) (xmlAttribute, bool) {
+	for _, attribute := range e.attributes {
+		if attribute.name == name {
+			return attribute, true
+		}
+	}
+	return xmlAttribute{}, false
+}
+
+func formatXMLName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// parseXMLDocument returns the root element of an XML document
+func parseXMLDocument(content string) (*xmlElement, error) {
+	var (
+		root  *xmlElement
+		stack []*xmlElement
+	)
+
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %s", err)
+		}
+		end := int(decoder.InputOffset())
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			element := &xmlElement{
+				name:        formatXMLName(token.Name),
+				start:       start,
+				startEnd:    end,
+				selfClosing: strings.HasSuffix(content[start:end], "/>"),
+			}
+
+			if element.attributes, err = parseXMLAttributes(content[start:end], start, token.Attr); err != nil {
+				return nil, err
+			}
+
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, element)
+			case root != nil:
+				return nil, fmt.Errorf("invalid XML: multiple root elements")
+			default:
+				root = element
+			}
+			stack = append(stack, element)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("invalid XML: unexpected end element </%s>", formatXMLName(token.Name))
+			}
+
+			element := stack[len(stack)-1]
+			if name := formatXMLName(token.Name); name != element.name {
+				return nil, fmt.Errorf("invalid XML: element <%s> closed by </%s>", element.name, name)
+			}
+			element.end, element.closeEnd = start, end
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("invalid XML: missing root element")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("invalid XML: unclosed element <%s>", stack[len(stack)-1].name)
+	}
+
+	return root, nil
+}
+
+// parseXMLAttributes returns the attributes of a start tag starting at offset, along with the
+// offsets of their values
+func parseXMLAttributes(tag string, offset int, decoded []xml.Attr) ([]xmlAttribute, error) {
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\n' }
+
+	i := 1
+	for i < len(tag) && !isSpace(tag[i]) && tag[i] != '/' && tag[i] != '>' {
+		i++
+	}
+
+	var attributes []xmlAttribute
+	for {
+		space := i
+		for i < len(tag) && isSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) || tag[i] == '/' || tag[i] == '>' {
+			break
+		}
+
+		equal := strings.IndexByte(tag[i:], '=')
+		if equal < 0 {
+			return nil, fmt.Errorf("invalid XML: invalid attribute in %s", tag)
+		}
+		i += equal + 1
+		for i < len(tag) && isSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) {
+			return nil, fmt.Errorf("invalid XML: invalid attribute in %s", tag)
+		}
+
+		end := strings.IndexByte(tag[i+1:], tag[i])
+		if end < 0 || len(attributes) >= len(decoded) {
+			return nil, fmt.Errorf("invalid XML: invalid attribute in %s", tag)
+		}
+
+		attributes = append(attributes, xmlAttribute{
+			name:   formatXMLName(decoded[len(attributes)].Name),
+			value:  decoded[len(attributes)].Value,
+			offset: offset + space,
+			start:  offset + i + 1,
+			end:    offset + i + 1 + end,
+		})
+		i += end + 2
+	}
+
+	return attributes, nil
+}
+
+// xmlSelect returns the elements selected by location steps, from the document root
+func xmlSelect(root *xmlElement, steps []xmlStep) []*xmlElement {
+	elements := []*xmlElement{{children: []*xmlElement{root}}}
+	for _, step := range steps {
+		var selected []*xmlElement
+		for _, element := range elements {
+			position := 0
+			for _, child := range element.children {
+				if !step.matches(child) {
+					continue
+				}
+				position++
+				if step.position == 0 || step.position == position {
+					selected = append(selected, child)
+				}
+			}
+		}
+		elements = selected
+	}
+	return elements
+}
+
+// xmlSelectOne returns the element selected by location steps, if any, failing if several elements
+// are selected
+func xmlSelectOne(root *xmlElement, path xmlPath, steps []xmlStep) (*xmlElement, error) {
+	switch elements := xmlSelect(root, steps); len(elements) {
+	case 0:
+		return nil, nil
+	case 1:
+		return elements[0], nil
+	default:
+		return nil, fmt.Errorf("XPath %q selects %d elements", path.expression, len(elements))
+	}
+}
+
+// xmlGet returns the value of the node selected by an XPath expression, and whether it exists
+func xmlGet(content string, path xmlPath) (string, bool, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return "", false, err
+	}
+
+	element, err := xmlSelectOne(root, path, path.steps)
+	if err != nil || element == nil {
+		return "", false, err
+	}
+
+	if path.attribute != "" {
+		attribute, ok := element.attribute(path.attribute)
+		return attribute.value, ok, nil
+	}
+
+	return element.text, true, nil
+}
+
+// xmlSet sets the value of the node selected by an XPath expression. Missing nodes are created if
+// create is set, along with their missing ancestors (with the attributes of their predicates),
+// elements being added after the last child element of their parent.
+func xmlSet(content string, path xmlPath, value string, create bool) (string, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return "", err
+	}
+
+	element, err := xmlSelectOne(root, path, path.steps)
+	if err != nil {
+		return "", err
+	}
+
+	if element == nil {
+		if !create {
+			return "", fmt.Errorf("XPath %q selects no element", path.expression)
+		}
+
+		if content, err = xmlCreate(content, path); err != nil {
+			return "", err
+		}
+		return xmlSet(content, path, value, create)
+	}
+
+	if path.attribute != "" {
+		attribute, ok := element.attribute(path.attribute)
+		switch {
+		case ok && attribute.value == value:
+			return content, nil
+		case ok:
+			return splice(content, attribute.start, attribute.end, escapeXMLAttribute(value, content[attribute.start-1])), nil
+		case !create:
+			return "", fmt.Errorf("XPath %q selects no attribute", path.expression)
+		default:
+			end := xmlStartTagEnd(content, element)
+			return splice(content, end, end, fmt.Sprintf(" %s=\"%s\"", path.attribute, escapeXMLAttribute(value, '"'))), nil
+		}
+	}
+
+	switch {
+	case element.text == value:
+		return content, nil
+	case len(element.children) > 0:
+		return "", fmt.Errorf("unable to set the text of %q: element has child elements", path.expression)
+	case element.selfClosing:
+		return splice(content, xmlStartTagEnd(content, element), element.closeEnd, ">"+escapeXMLText(value)+"</"+element.name+">"), nil
+	default:
+		return splice(content, element.startEnd, element.end, escapeXMLText(value)), nil
+	}
+}
+
+// xmlCreate adds the missing elements selected by the location steps of an XPath expression, one
+// by one
+func xmlCreate(content string, path xmlPath) (string, error) {
+	for {
+		root, err := parseXMLDocument(content)
+		if err != nil {
+			return "", err
+		}
+
+		// The first missing element is added to its parent
+		var parent *xmlElement
+		missing := -1
+		for i := range path.steps {
+			element, err := xmlSelectOne(root, path, path.steps[:i+1])
+			if err != nil {
+				return "", err
+			}
+			if element == nil {
+				missing = i
+				break
+			}
+			parent = element
+		}
+
+		if missing < 0 {
+			return content, nil
+		}
+
+		step := path.steps[missing]
+		if parent == nil {
+			return "", fmt.Errorf("unable to create %q: root element is not <%s>", path.expression, step.name)
+		}
+
+		if step.position > 0 {
+			unpositioned := append(append([]xmlStep{}, path.steps[:missing]...), xmlStep{name: step.name, attributes: step.attributes})
+			if step.position != len(xmlSelect(root, unpositioned))+1 {
+				return "", fmt.Errorf("unable to create %q: position %d of <%s> does not follow the existing elements", path.expression, step.position, step.name)
+			}
+		}
+
+		markup := "<" + step.name
+		for _, attribute := range step.attributes {
+			markup += fmt.Sprintf(" %s=\"%s\"", attribute.name, escapeXMLAttribute(attribute.value, '"'))
+		}
+
+		if content, err = xmlAddChild(content, parent, markup+"/>"); err != nil {
+			return "", fmt.Errorf("unable to create %q: %s", path.expression, err)
+		}
+	}
+}
+
+// xmlAddChild adds an element to parent, after its last child element with the same indentation,
+// or one indentation level deeper than the parent
+func xmlAddChild(content string, parent *xmlElement, markup string) (string, error) {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+
+	if len(parent.children) > 0 {
+		last := parent.children[len(parent.children)-1]
+		return splice(content, last.closeEnd, last.closeEnd, newline+xmlIndent(content, last.start)+markup), nil
+	}
+
+	if strings.TrimSpace(parent.text) != "" {
+		return "", fmt.Errorf("element <%s> has text", parent.name)
+	}
+
+	indent := xmlIndent(content, parent.start)
+	child := newline + indent + xmlIndentUnit(content) + markup + newline + indent
+
+	if parent.selfClosing {
+		return splice(content, xmlStartTagEnd(content, parent), parent.closeEnd, ">"+child+"</"+parent.name+">"), nil
+	}
+	return splice(content, parent.startEnd, parent.end, child), nil
+}
+
+// xmlIndent returns the indentation of the line of an element, if it starts the line
+func xmlIndent(content string, offset int) string {
+	indent := content[strings.LastIndexByte(content[:offset], '\n')+1 : offset]
+	if strings.TrimLeft(indent, " \t") != "" {
+		return ""
+	}
+	return indent
+}
+
+// xmlIndentUnitRegexp matches the indentation of the first indented element of an XML document
+var xmlIndentUnitRegexp = regexp.MustCompile(`\n([ \t]+)<`)
+
+// xmlIndentUnit returns the indentation unit of an XML document (two spaces by default)
+func xmlIndentUnit(content string) string {
+	if match := xmlIndentUnitRegexp.FindStringSubmatch(content); match != nil {
+		return match[1]
+	}
+	return "  "
+}
+
+// xmlStartTagEnd returns the offset following the name and attributes of the start tag of an
+// element, trailing whitespace excluded
+func xmlStartTagEnd(content string, element *xmlElement) int {
+	end := element.startEnd - 1
+	if element.selfClosing {
+		end--
+	}
+	for end > element.start && strings.ContainsAny(content[end-1:end], " \t\r\n") {
+		end--
+	}
+	return end
+}
+
+// xmlMissingSteps returns the number of trailing location steps of an XPath expression selecting
+// no element
+func xmlMissingSteps(content string, path xmlPath) (int, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range path.steps {
+		element, err := xmlSelectOne(root, path, path.steps[:i+1])
+		if err != nil {
+			return 0, err
+		}
+		if element == nil {
+			return len(path.steps) - i, nil
+		}
+	}
+	return 0, nil
+}
+
+// xmlDeleteCreated removes the elements selected by the last count location steps of an XPath
+// expression whose node was removed, deepest first, as long as they are left without child
+// elements nor text
+func xmlDeleteCreated(content string, path xmlPath, count int) (string, error) {
+	last := len(path.steps)
+	if path.attribute == "" {
+		// The selected element was removed along with its text
+		last--
+	}
+
+	for i := last; i > len(path.steps)-count && i > 1; i-- {
+		ancestor := xmlPath{expression: path.expression, steps: path.steps[:i]}
+
+		root, err := parseXMLDocument(content)
+		if err != nil {
+			return "", err
+		}
+
+		element, err := xmlSelectOne(root, ancestor, ancestor.steps)
+		if err != nil {
+			return "", err
+		}
+		if element == nil {
+			continue
+		}
+		if len(element.children) > 0 || strings.TrimSpace(element.text) != "" {
+			break
+		}
+
+		if content, err = xmlDelete(content, ancestor); err != nil {
+			return "", err
+		}
+	}
+
+	return content, nil
+}
+
+// xmlDelete removes the node selected by an XPath expression, if any: either an attribute, or an
+// element along with its line if it stands alone on it
+func xmlDelete(content string, path xmlPath) (string, error) {
+	root, err := parseXMLDocument(content)
+	if err != nil {
+		return "", err
+	}
+
+	element, err := xmlSelectOne(root, path, path.steps)
+	if err != nil || element == nil {
+		return content, err
+	}
+
+	if path.attribute != "" {
+		attribute, ok := element.attribute(path.attribute)
+		if !ok {
+			return content, nil
+		}
+		return splice(content, attribute.offset, attribute.end+1, ""), nil
+	}
+
+	if element == root {
+		return "", fmt.Errorf("unable to remove %q: root element", path.expression)
+	}
+
+	start, end := element.start, element.closeEnd
+	if indent := xmlIndent(content, start); start-len(indent) > 0 && content[start-len(indent)-1] == '\n' {
+		start -= len(indent) + 1
+		if start > 0 && content[start-1] == '\r' {
+			start--
+		}
+	}
+
+	return splice(content, start, end, ""), nil
+}
+
+func splice(s string, start, end int, replacement string) string {
+	return s[:start] + replacement + s[end:]
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+	xmlAttributeEscapers = map[byte]*strings.Replacer{
+		'"':  strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;"),
+		'\'': strings.NewReplacer("&", "&amp;", "<", "&lt;", "'", "&apos;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;"),
+	}
+)
+
+func escapeXMLText(s string) string {
+	return xmlTextEscaper.Replace(s)
+}
+
+// escapeXMLAttribute escapes an attribute value quoted with quote
+func escapeXMLAttribute(s string, quote byte) string {
+	return xmlAttributeEscapers[quote].Replace(s)
+}
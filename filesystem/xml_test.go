@@ -0,0 +1,226 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+)
+
+const testXMLContent = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- Tomcat configuration -->
+<Server port="8005" shutdown="SHUTDOWN">
+  <Service name="Catalina">
+    <Connector port="8080" protocol="HTTP/1.1" connectionTimeout='20000'/>
+    <Engine name="Catalina" defaultHost="localhost">
+      <Realm className="org.apache.catalina.realm.LockOutRealm"/>
+    </Engine>
+  </Service>
+  <Description>Tomcat &amp; friends</Description>
+</Server>
+`
+
+func TestParseXPath(t *testing.T) {
+	cases := map[string]struct {
+		Expression string
+		Valid      bool
+	}{
+		"element":              {Expression: "/Server/Description", Valid: true},
+		"text":                 {Expression: "/Server/Description/text()", Valid: true},
+		"attribute":            {Expression: "/Server/Service[@name='Catalina']/Connector[1]/@port", Valid: true},
+		"predicates":           {Expression: `/Server/Service[@name="Catalina"][@x = 'a]b'][2]`, Valid: true},
+		"relative":             {Expression: "Server", Valid: false},
+		"descendant":           {Expression: "//Connector", Valid: false},
+		"attribute not last":   {Expression: "/Server/@port/Service", Valid: false},
+		"root attribute":       {Expression: "/@port", Valid: false},
+		"position not last":    {Expression: "/Server/Service[1][@name='Catalina']", Valid: false},
+		"unsupported function": {Expression: "/Server/Service[last()]", Valid: false},
+		"zero position":        {Expression: "/Server/Service[0]", Valid: false},
+		"trailing slash":       {Expression: "/Server/", Valid: false},
+	}
+
+	for name, tc := range cases {
+		if _, err := parseXPath(tc.Expression); (err == nil) != tc.Valid {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestXMLSet(t *testing.T) {
+	cases := map[string]struct {
+		XPath    string
+		Value    string
+		Create   bool
+		Expected string
+	}{
+		"attribute replaced": {
+			XPath:    "/Server/Service[@name='Catalina']/Connector[@port='8080']/@connectionTimeout",
+			Value:    "'30000'",
+			Expected: `<Connector port="8080" protocol="HTTP/1.1" connectionTimeout='&apos;30000&apos;'/>`,
+		},
+		"attribute added": {
+			XPath:    "/Server/Service/Connector/@redirectPort",
+			Value:    "8443",
+			Create:   true,
+			Expected: `<Connector port="8080" protocol="HTTP/1.1" connectionTimeout='20000' redirectPort="8443"/>`,
+		},
+		"text replaced": {
+			XPath:    "/Server/Description",
+			Value:    "Tomcat <9>",
+			Expected: `<Description>Tomcat &lt;9&gt;</Description>`,
+		},
+		"text of self-closing element": {
+			XPath:    "/Server/Service/Engine/Realm/text()",
+			Value:    "realm",
+			Expected: `<Realm className="org.apache.catalina.realm.LockOutRealm">realm</Realm>`,
+		},
+		"element created": {
+			XPath:    "/Server/Service/Connector[@port='8443']/@SSLEnabled",
+			Value:    "true",
+			Create:   true,
+			Expected: "connectionTimeout='20000'/>\n    <Engine name=\"Catalina\" defaultHost=\"localhost\">\n      <Realm className=\"org.apache.catalina.realm.LockOutRealm\"/>\n    </Engine>\n    <Connector port=\"8443\" SSLEnabled=\"true\"/>\n  </Service>",
+		},
+		"elements created": {
+			XPath:    "/Server/Service/Engine/Realm/CredentialHandler/Algorithm",
+			Value:    "SHA-256",
+			Create:   true,
+			Expected: "<Realm className=\"org.apache.catalina.realm.LockOutRealm\">\n        <CredentialHandler>\n          <Algorithm>SHA-256</Algorithm>\n        </CredentialHandler>\n      </Realm>",
+		},
+	}
+
+	for name, tc := range cases {
+		path, err := parseXPath(tc.XPath)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		content, err := xmlSet(testXMLContent, path, tc.Value, tc.Create)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !strings.Contains(content, tc.Expected) {
+			t.Fatalf("%s: content (%q) doesn't hold expected content (%q)", name, content, tc.Expected)
+		}
+
+		if value, ok, _ := xmlGet(content, path); !ok || value != tc.Value {
+			t.Fatalf("%s: value (%q) different from expected value (%q)", name, value, tc.Value)
+		}
+
+		// The rest of the document is left unchanged
+		if unchanged, err := xmlSet(content, path, tc.Value, false); err != nil || unchanged != content {
+			t.Fatalf("%s: content changed when setting the same value (%v)", name, err)
+		}
+	}
+}
+
+func TestXMLSetErrors(t *testing.T) {
+	cases := map[string]struct {
+		XPath  string
+		Create bool
+	}{
+		"missing element":    {XPath: "/Server/Listener/@className"},
+		"missing attribute":  {XPath: "/Server/Service/@id"},
+		"element with child": {XPath: "/Server/Service"},
+		"other root":         {XPath: "/Context/@path", Create: true},
+		"position not next":  {XPath: "/Server/Service[3]/@name", Create: true},
+		"element with text":  {XPath: "/Server/Description/Lang", Create: true},
+	}
+
+	for name, tc := range cases {
+		path, err := parseXPath(tc.XPath)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if _, err := xmlSet(testXMLContent, path, "value", tc.Create); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestXMLDelete(t *testing.T) {
+	cases := map[string]struct {
+		XPath    string
+		Expected string
+	}{
+		"attribute": {
+			XPath:    "/Server/Service/Connector/@protocol",
+			Expected: "<Connector port=\"8080\" connectionTimeout='20000'/>",
+		},
+		"element": {
+			XPath:    "/Server/Service/Engine/Realm",
+			Expected: "<Engine name=\"Catalina\" defaultHost=\"localhost\">\n    </Engine>",
+		},
+	}
+
+	for name, tc := range cases {
+		path, _ := parseXPath(tc.XPath)
+
+		content, err := xmlDelete(testXMLContent, path)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !strings.Contains(content, tc.Expected) {
+			t.Fatalf("%s: content (%q) doesn't hold expected content (%q)", name, content, tc.Expected)
+		}
+
+		if _, ok, _ := xmlGet(content, path); ok {
+			t.Fatalf("%s: node not removed", name)
+		}
+	}
+}
+
+func TestXMLDeleteCreated(t *testing.T) {
+	cases := map[string]struct {
+		XPath    string
+		Other    string
+		Expected string
+	}{
+		"attribute": {
+			XPath:    "/Server/Service[@name='Catalina']/Connector[@port='8443']/@SSLEnabled",
+			Expected: testXMLContent,
+		},
+		"element text": {
+			XPath:    "/Server/Listener[@className='VersionLoggerListener']/Description",
+			Expected: testXMLContent,
+		},
+		"ancestor holding other nodes": {
+			XPath:    "/Server/GlobalNamingResources/Resource[@name='UserDatabase']/@auth",
+			Other:    "/Server/GlobalNamingResources/Resource[@name='Pool']/@auth",
+			Expected: "  <GlobalNamingResources>\n    <Resource name=\"Pool\" auth=\"Container\"/>\n  </GlobalNamingResources>\n</Server>",
+		},
+	}
+
+	for name, tc := range cases {
+		path, _ := parseXPath(tc.XPath)
+
+		missing, err := xmlMissingSteps(testXMLContent, path)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		content, err := xmlSet(testXMLContent, path, "Container", true)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if tc.Other != "" {
+			other, _ := parseXPath(tc.Other)
+			if content, err = xmlSet(content, other, "Container", true); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+
+		if content, err = xmlDelete(content, path); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if content, err = xmlDeleteCreated(content, path, missing); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !strings.Contains(content, tc.Expected) {
+			t.Fatalf("%s: content (%q) doesn't hold expected content (%q)", name, content, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,957 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// The following functions edit YAML documents as yaml.v3 nodes, so that comments and key order
+// are preserved. Only the first document of YAML streams is edited, the other ones being left
+// unchanged. Values are addressed by dotted paths of mapping keys and sequence indexes.
+
+// decodeYAMLNode decodes a YAML document into a node
+func decodeYAMLNode(s string) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &document); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %s", err)
+	}
+
+	if len(document.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return document.Content[0], nil
+}
+
+// encodeYAMLNode encodes a node into a YAML document, without its trailing newline
+func encodeYAMLNode(node *yaml.Node) (string, error) {
+	encoded, err := yaml.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(encoded), "\n"), nil
+}
+
+// yamlIndentRegexp matches the indentation of the first indented line of a YAML document
+var yamlIndentRegexp = regexp.MustCompile(`\n( +)[^\s-]`)
+
+// editYAML applies edit to the root node of the first document of the YAML stream held by content
+// (an empty mapping if content holds none). Only the text of the nodes changed by edit is
+// rewritten, so that the formatting of the rest of the stream (blank lines, quoting, flow style,
+// indentation) is preserved; added nodes are indented like content (two spaces by default).
+func editYAML(content string, edit func(*yaml.Node) (*yaml.Node, error)) (string, error) {
+	var documents []*yaml.Node
+	for decoder := yaml.NewDecoder(strings.NewReader(content)); ; {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("invalid YAML: %s", err)
+		}
+		documents = append(documents, &document)
+	}
+
+	editor := newYAMLEditor(content)
+
+	if len(documents) == 0 || len(documents[0].Content) == 0 {
+		if len(documents) > 1 {
+			return "", fmt.Errorf("unable to edit YAML stream: empty first document")
+		}
+
+		root, err := edit(newYAMLMapping())
+		if err != nil {
+			return "", err
+		}
+
+		encoded, err := editor.encode(root)
+		if err != nil {
+			return "", err
+		}
+
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += editor.newline
+		}
+		return content + strings.Replace(encoded, "\n", editor.newline, -1) + editor.newline, nil
+	}
+
+	root := documents[0].Content[0]
+
+	edited, err := edit(copyYAMLNode(root, editor.origin))
+	if err != nil {
+		return "", err
+	}
+
+	if err := editor.reconcile(root, edited, -1, false); err != nil {
+		return "", err
+	}
+
+	return editor.apply(), nil
+}
+
+// copyYAMLNode returns a deep copy of a node, recording the node each copied node originates from
+// into origin
+func copyYAMLNode(node *yaml.Node, origin map[*yaml.Node]*yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyYAMLNode(child, origin)
+	}
+
+	origin[&copied] = node
+	return &copied
+}
+
+// equalYAMLNodes reports whether two nodes hold the same values, regardless of their style and
+// comments
+func equalYAMLNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Tag != b.Tag || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+
+	for i := range a.Content {
+		if !equalYAMLNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlSplice replaces the content between start and end by text
+type yamlSplice struct {
+	start, end int
+	text       string
+}
+
+// yamlEditor rewrites the text of the nodes decoded from a YAML document that differ from their
+// edited copies, locating the nodes in the document from their line and column
+type yamlEditor struct {
+	content string
+	newline string
+	lines   []int
+	indent  int
+	origin  map[*yaml.Node]*yaml.Node
+	splices []yamlSplice
+}
+
+func newYAMLEditor(content string) *yamlEditor {
+	e := &yamlEditor{content: content, newline: "\n", lines: []int{0}, indent: 2, origin: map[*yaml.Node]*yaml.Node{}}
+	if strings.Contains(content, "\r\n") {
+		e.newline = "\r\n"
+	}
+
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			e.lines = append(e.lines, i+1)
+		}
+	}
+
+	if match := yamlIndentRegexp.FindStringSubmatch(content); match != nil {
+		e.indent = len(match[1])
+	}
+
+	return e
+}
+
+// apply returns the content with the splices applied
+func (e *yamlEditor) apply() string {
+	// Splices are applied from the end of the content, for the offsets of the others to remain
+	// valid; insertions at the same offset are applied in reverse order, for the text of the first
+	// recorded to come first
+	splices := make([]yamlSplice, len(e.splices))
+	for i, splice := range e.splices {
+		splices[len(splices)-1-i] = splice
+	}
+	sort.SliceStable(splices, func(i, j int) bool {
+		if splices[i].start != splices[j].start {
+			return splices[i].start > splices[j].start
+		}
+		return splices[i].end > splices[j].end
+	})
+
+	content := e.content
+	for _, s := range splices {
+		content = splice(content, s.start, s.end, strings.Replace(s.text, "\n", e.newline, -1))
+	}
+	return content
+}
+
+// encode encodes a node with the indentation of the document, without its trailing newline
+func (e *yamlEditor) encode(node *yaml.Node) (string, error) {
+	var encoded bytes.Buffer
+
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(e.indent)
+
+	if err := encoder.Encode(node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(encoded.String(), "\n"), nil
+}
+
+// offset returns the offset of the position of a node (that of its anchor or tag, if any)
+func (e *yamlEditor) offset(node *yaml.Node) int {
+	offset := e.lines[node.Line-1]
+	for column := 1; column < node.Column && offset < len(e.content); column++ {
+		_, size := utf8.DecodeRuneInString(e.content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// start returns the offset of the value of a scalar or flow collection node, after its anchor and
+// tag
+func (e *yamlEditor) start(node *yaml.Node) int {
+	i := e.offset(node)
+	for i < len(e.content) && (e.content[i] == '&' || e.content[i] == '!') {
+		for i < len(e.content) && !strings.ContainsRune(" \t\r\n", rune(e.content[i])) {
+			i++
+		}
+		for i < len(e.content) && (e.content[i] == ' ' || e.content[i] == '\t') {
+			i++
+		}
+	}
+	return i
+}
+
+func (e *yamlEditor) lineStart(offset int) int {
+	return strings.LastIndexByte(e.content[:offset], '\n') + 1
+}
+
+// nextLine returns the offset of the line following the line of offset (the end of the content
+// for the last line)
+func (e *yamlEditor) nextLine(offset int) int {
+	if i := strings.IndexByte(e.content[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(e.content)
+}
+
+// lineEnd returns the offset of the separator ending the line of offset
+func (e *yamlEditor) lineEnd(offset int) int {
+	end := e.nextLine(offset)
+	if end > offset && e.content[end-1] == '\n' {
+		end--
+	}
+	if end > offset && e.content[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// column returns the column of offset in its line, in bytes
+func (e *yamlEditor) column(offset int) int {
+	return offset - e.lineStart(offset)
+}
+
+// lineIndent returns the indentation of the line starting at offset, and whether it is blank
+func (e *yamlEditor) lineIndent(offset int) (int, bool) {
+	line := e.content[offset:e.lineEnd(offset)]
+	trimmed := strings.TrimLeft(line, " ")
+	return len(line) - len(trimmed), strings.TrimSpace(trimmed) == ""
+}
+
+// dash returns the offset of the dash of a block sequence item
+func (e *yamlEditor) dash(item *yaml.Node) int {
+	i := e.offset(item)
+	for i > 0 && e.content[i-1] != '-' {
+		i--
+	}
+	return i - 1
+}
+
+// end returns the offset following the value of a node (before its line comment). Block scalars
+// and multi-line plain scalars span the following lines indented by more than indent, the
+// indentation of the mapping key or sequence dash introducing the node (-1 for the root node).
+func (e *yamlEditor) end(node *yaml.Node, indent int, flow bool) int {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		if node.Style&yaml.FlowStyle != 0 || len(node.Content) == 0 {
+			return e.flowEnd(e.start(node))
+		}
+
+		last := node.Content[len(node.Content)-1]
+		if node.Kind == yaml.MappingNode {
+			return e.end(last, e.column(e.offset(node.Content[len(node.Content)-2])), false)
+		}
+		return e.end(last, e.column(e.dash(last)), false)
+	}
+
+	i := e.start(node)
+	switch {
+	case node.Kind == yaml.AliasNode:
+		return i + 1 + len(node.Value)
+
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i++; i < len(e.content) && e.content[i] != '"'; i++ {
+			if e.content[i] == '\\' {
+				i++
+			}
+		}
+		return i + 1
+
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i++; i < len(e.content); i++ {
+			if e.content[i] == '\'' {
+				if i+1 < len(e.content) && e.content[i+1] == '\'' {
+					i++
+					continue
+				}
+				break
+			}
+		}
+		return i + 1
+
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		end := i + 1
+		for end < len(e.content) && strings.ContainsRune("+-0123456789", rune(e.content[end])) {
+			end++
+		}
+		for line := e.nextLine(end); line < len(e.content); line = e.nextLine(line) {
+			lineIndent, blank := e.lineIndent(line)
+			if !blank && lineIndent <= indent {
+				break
+			}
+			if !blank {
+				end = len(strings.TrimRight(e.content[:e.lineEnd(line)], " \t\r"))
+			}
+		}
+		return end
+	}
+
+	// Plain scalars are written verbatim unless they span several lines
+	if strings.HasPrefix(e.content[i:], node.Value) {
+		return i + len(node.Value)
+	}
+
+	end := e.plainLineEnd(i, flow)
+	for line := e.nextLine(end); !flow && line < len(e.content); line = e.nextLine(line) {
+		lineIndent, blank := e.lineIndent(line)
+		if blank || lineIndent <= indent || e.content[line+lineIndent] == '#' {
+			break
+		}
+		end = e.plainLineEnd(line+lineIndent, flow)
+	}
+	return end
+}
+
+// plainLineEnd returns the offset following the part of a plain scalar on the line of offset
+func (e *yamlEditor) plainLineEnd(offset int, flow bool) int {
+	end := e.lineEnd(offset)
+	for i := offset; i < end; i++ {
+		if e.content[i] == '#' && i > offset && (e.content[i-1] == ' ' || e.content[i-1] == '\t') ||
+			flow && strings.ContainsRune(",]}", rune(e.content[i])) {
+			end = i
+			break
+		}
+	}
+	return len(strings.TrimRight(e.content[:end], " \t\r"))
+}
+
+// flowEnd returns the offset following the flow collection starting at offset
+func (e *yamlEditor) flowEnd(offset int) int {
+	depth := 0
+	for i := offset; i < len(e.content); i++ {
+		switch c := e.content[i]; c {
+		case '[', '{':
+			depth++
+
+		case ']', '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+
+		case '"', '\'':
+			for i++; i < len(e.content) && e.content[i] != c; i++ {
+				if c == '"' && e.content[i] == '\\' {
+					i++
+				}
+			}
+
+		case '#':
+			if i > offset && (e.content[i-1] == ' ' || e.content[i-1] == '\t' || e.content[i-1] == '\n') {
+				i = e.lineEnd(i)
+			}
+		}
+	}
+	return len(e.content)
+}
+
+// indentLines indents the lines following the first line of s by indent spaces
+func indentLines(s string, indent int) string {
+	return strings.Replace(s, "\n", "\n"+strings.Repeat(" ", indent), -1)
+}
+
+// reconcile records the splices rewriting the text of old into that of new, old being introduced
+// by a mapping key or sequence dash indented by indent (-1 for the root node) and being part of a
+// flow collection if flow is set
+func (e *yamlEditor) reconcile(old, new *yaml.Node, indent int, flow bool) error {
+	if equalYAMLNodes(old, new) {
+		return nil
+	}
+
+	if old.Kind == new.Kind && len(old.Content) > 0 && len(new.Content) > 0 {
+		switch {
+		case old.Style&yaml.FlowStyle != 0 || flow:
+			// Flow collections are rewritten as a whole, unless only their values changed
+			if len(old.Content) != len(new.Content) {
+				break
+			}
+			for i := range old.Content {
+				if old.Kind == yaml.MappingNode && i%2 == 0 && old.Content[i].Value != new.Content[i].Value {
+					return e.replace(old, new, indent, flow)
+				}
+			}
+			for i := range old.Content {
+				if err := e.reconcile(old.Content[i], new.Content[i], indent, true); err != nil {
+					return err
+				}
+			}
+			return nil
+
+		case old.Kind == yaml.MappingNode:
+			return e.reconcileMapping(old, new, indent)
+
+		case old.Kind == yaml.SequenceNode:
+			return e.reconcileSequence(old, new)
+		}
+	}
+
+	return e.replace(old, new, indent, flow)
+}
+
+// remove records the splice removing the content from start to the end of the line of end, and the
+// lines it leaves blank along with their newline
+func (e *yamlEditor) remove(start, end int) {
+	end = e.lineEnd(end)
+
+	lineStart := e.lineStart(start)
+	switch {
+	case strings.TrimSpace(e.content[lineStart:start]) != "":
+		// Nodes following a sequence dash are replaced by the content of the next line
+		for end < len(e.content) && strings.ContainsRune("\r\n ", rune(e.content[end])) {
+			end++
+		}
+
+	case lineStart > 0:
+		start = lineStart - 1
+		if start > 0 && e.content[start-1] == '\r' {
+			start--
+		}
+
+	default:
+		start, end = 0, e.nextLine(end)
+	}
+
+	e.splices = append(e.splices, yamlSplice{start: start, end: end})
+}
+
+// reconcileMapping records the splices rewriting a block mapping: values of existing keys are
+// reconciled, removed keys are removed along with their head comment, and added keys are appended
+// after the last key. The mapping is replaced as a whole if none of its keys is kept.
+func (e *yamlEditor) reconcileMapping(old, new *yaml.Node, parentIndent int) error {
+	var kept bool
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		kept = kept || yamlMappingIndex(new, old.Content[i].Value) >= 0
+	}
+	if !kept {
+		return e.replace(old, new, parentIndent, false)
+	}
+
+	indent := e.column(e.offset(old.Content[0]))
+
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key, value := old.Content[i], old.Content[i+1]
+		if j := yamlMappingIndex(new, key.Value); j >= 0 {
+			if err := e.reconcile(value, new.Content[j], indent, false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The head comment of the key is removed along with it, when right above it
+		start := e.offset(key)
+		for key.HeadComment != "" && e.lineStart(start) > 0 {
+			previous := e.lineStart(e.lineStart(start) - 1)
+			if !strings.HasPrefix(strings.TrimSpace(e.content[previous:e.lineEnd(previous)]), "#") {
+				break
+			}
+			start = previous
+		}
+		e.remove(start, e.end(value, indent, false))
+	}
+
+	added := newYAMLMapping()
+	for i := 0; i+1 < len(new.Content); i += 2 {
+		if yamlMappingIndex(old, new.Content[i].Value) < 0 {
+			added.Content = append(added.Content, new.Content[i], new.Content[i+1])
+		}
+	}
+	if len(added.Content) == 0 {
+		return nil
+	}
+
+	encoded, err := e.encode(added)
+	if err != nil {
+		return err
+	}
+
+	end := e.lineEnd(e.end(old.Content[len(old.Content)-1], indent, false))
+	e.splices = append(e.splices, yamlSplice{start: end, end: end, text: "\n" + strings.Repeat(" ", indent) + indentLines(encoded, indent)})
+	return nil
+}
+
+// reconcileSequence records the splices rewriting a block sequence: items originating from old
+// items are reconciled with them, other items replacing the old items at their position, or being
+// inserted otherwise
+func (e *yamlEditor) reconcileSequence(old, new *yaml.Node) error {
+	indent := e.column(e.dash(old.Content[0]))
+
+	index := map[*yaml.Node]int{}
+	for i, item := range old.Content {
+		index[item] = i
+	}
+	kept := map[*yaml.Node]bool{}
+	for _, item := range new.Content {
+		kept[e.origin[item]] = true
+	}
+
+	remove := func(item *yaml.Node) {
+		e.remove(e.dash(item), e.end(item, indent, false))
+	}
+
+	encodeItem := func(item *yaml.Node) (string, error) {
+		encoded, err := e.encode(item)
+		return "- " + indentLines(encoded, indent+2), err
+	}
+
+	i := 0
+	for _, item := range new.Content {
+		if j, ok := index[e.origin[item]]; ok && j >= i {
+			for ; i < j; i++ {
+				remove(old.Content[i])
+			}
+			if err := e.reconcile(old.Content[i], item, indent, false); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+
+		if i < len(old.Content) && !kept[old.Content[i]] {
+			if err := e.reconcile(old.Content[i], item, indent, false); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+
+		encoded, err := encodeItem(item)
+		if err != nil {
+			return err
+		}
+
+		if i < len(old.Content) {
+			start := e.dash(old.Content[i])
+			e.splices = append(e.splices, yamlSplice{start: start, end: start, text: encoded + "\n" + strings.Repeat(" ", indent)})
+		} else {
+			end := e.lineEnd(e.end(old.Content[len(old.Content)-1], indent, false))
+			e.splices = append(e.splices, yamlSplice{start: end, end: end, text: "\n" + strings.Repeat(" ", indent) + encoded})
+		}
+	}
+
+	for ; i < len(old.Content); i++ {
+		remove(old.Content[i])
+	}
+
+	return nil
+}
+
+// replace records the splice replacing the text of old by the encoding of new
+func (e *yamlEditor) replace(old, new *yaml.Node, indent int, flow bool) error {
+	// The comments of old are kept in place
+	node := *new
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+
+	if flow {
+		if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+			node.Style |= yaml.FlowStyle
+		} else if strings.Contains(node.Value, "\n") {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+
+	encoded, err := e.encode(&node)
+	if err != nil {
+		return err
+	}
+
+	start, end := e.offset(old), e.end(old, indent, flow)
+	if indent < 0 || flow {
+		e.splices = append(e.splices, yamlSplice{start: start, end: end, text: encoded})
+		return nil
+	}
+
+	// Values start after the indicator of their key or sequence dash, on the same line unless they
+	// are block collections following a key
+	indicator := start
+	for indicator > 0 && strings.ContainsRune(" \t\r\n", rune(e.content[indicator-1])) {
+		indicator--
+	}
+	if indicator == 0 || e.content[indicator-1] != ':' && e.content[indicator-1] != '-' {
+		e.splices = append(e.splices, yamlSplice{start: start, end: end, text: indentLines(encoded, indent+e.indent)})
+		return nil
+	}
+
+	switch {
+	case e.content[indicator-1] == '-':
+		encoded = " " + indentLines(encoded, indent+2)
+	case node.Kind == yaml.ScalarNode || node.Kind == yaml.AliasNode || node.Style&yaml.FlowStyle != 0 || len(node.Content) == 0:
+		encoded = " " + indentLines(encoded, indent+e.indent)
+	default:
+		encoded = "\n" + strings.Repeat(" ", indent+e.indent) + indentLines(encoded, indent+e.indent)
+	}
+	e.splices = append(e.splices, yamlSplice{start: indicator, end: end, text: encoded})
+	return nil
+}
+
+// equivalentYAML reports whether two YAML documents hold the same values
+func equivalentYAML(a, b string) bool {
+	var values [2]interface{}
+	for i, s := range []string{a, b} {
+		if err := yaml.Unmarshal([]byte(s), &values[i]); err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(values[0], values[1])
+}
+
+func newYAMLMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+// yamlMappingIndex returns the index of the value of key in the content of a mapping node (-1 if
+// missing)
+func yamlMappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// yamlReplace returns the node replacing old, which keeps the comments of old unless it has its
+// own
+func yamlReplace(old, new *yaml.Node) *yaml.Node {
+	if old == nil {
+		return new
+	}
+
+	replacement := *new
+	if replacement.HeadComment == "" {
+		replacement.HeadComment = old.HeadComment
+	}
+	if replacement.LineComment == "" {
+		replacement.LineComment = old.LineComment
+	}
+	if replacement.FootComment == "" {
+		replacement.FootComment = old.FootComment
+	}
+	return &replacement
+}
+
+// parseYAMLPath returns the mapping keys and sequence indexes of a dotted path, keys holding dots
+// being quoted with double (with Go escapes) or single quotes (e.g. `metadata.labels."app.kubernetes.io/name"`)
+func parseYAMLPath(path string) ([]string, error) {
+	var parts []string
+
+	i := 0
+	for {
+		var part string
+
+		switch {
+		case i < len(path) && path[i] == '"':
+			end := i + 1
+			for end < len(path) && path[end] != '"' {
+				if path[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(path) {
+				return nil, fmt.Errorf("invalid YAML path %q: unterminated quoted key", path)
+			}
+
+			var err error
+			if part, err = strconv.Unquote(path[i : end+1]); err != nil {
+				return nil, fmt.Errorf("invalid YAML path %q: invalid quoted key %s", path, path[i:end+1])
+			}
+			i = end + 1
+
+		case i < len(path) && path[i] == '\'':
+			end := strings.IndexByte(path[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid YAML path %q: unterminated quoted key", path)
+			}
+			part, i = path[i+1:i+1+end], i+end+2
+
+		default:
+			end := strings.IndexByte(path[i:], '.')
+			if end < 0 {
+				end = len(path) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid YAML path %q: empty key", path)
+			}
+			part, i = path[i:i+end], i+end
+		}
+
+		parts = append(parts, part)
+
+		if i == len(path) {
+			return parts, nil
+		}
+		if path[i] != '.' {
+			return nil, fmt.Errorf("invalid YAML path %q: unexpected character after quoted key", path)
+		}
+		i++
+	}
+}
+
+// yamlSequenceIndex returns the sequence index of a path part, up to max
+func yamlSequenceIndex(part string, max int) (int, error) {
+	index, err := strconv.Atoi(part)
+	if err != nil || index < 0 || index > max || strconv.Itoa(index) != part {
+		return 0, fmt.Errorf("invalid sequence index %q", part)
+	}
+	return index, nil
+}
+
+// yamlPathGet returns the node at a path, and whether it exists
+func yamlPathGet(node *yaml.Node, parts []string) (*yaml.Node, bool) {
+	if len(parts) == 0 {
+		return node, true
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if i := yamlMappingIndex(node, parts[0]); i >= 0 {
+			return yamlPathGet(node.Content[i], parts[1:])
+		}
+
+	case yaml.SequenceNode:
+		if index, err := yamlSequenceIndex(parts[0], len(node.Content)-1); err == nil {
+			return yamlPathGet(node.Content[index], parts[1:])
+		}
+	}
+
+	return nil, false
+}
+
+// yamlPathSet sets the node at a path, creating the missing mappings on its path, and returns the
+// updated node. Sequence elements can be replaced, or appended with the index following the last
+// element.
+func yamlPathSet(node *yaml.Node, parts []string, value *yaml.Node) (*yaml.Node, error) {
+	if len(parts) == 0 {
+		return yamlReplace(node, value), nil
+	}
+
+	if node == nil || node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		node = yamlReplace(node, newYAMLMapping())
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		i := yamlMappingIndex(node, parts[0])
+		if i < 0 {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: parts[0]}, nil)
+			i = len(node.Content) - 1
+		}
+
+		child, err := yamlPathSet(node.Content[i], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node.Content[i] = child
+		return node, nil
+
+	case yaml.SequenceNode:
+		index, err := yamlSequenceIndex(parts[0], len(node.Content))
+		if err != nil {
+			return nil, err
+		}
+
+		if index == len(node.Content) {
+			node.Content = append(node.Content, nil)
+		}
+
+		if node.Content[index], err = yamlPathSet(node.Content[index], parts[1:], value); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("%q: parent value is neither a mapping nor a sequence", parts[0])
+}
+
+// yamlPathRemove removes the node at a path, along with the mappings left empty by the removal,
+// and returns whether the node existed
+func yamlPathRemove(node *yaml.Node, parts []string) bool {
+	if len(parts) == 0 {
+		return false
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		i := yamlMappingIndex(node, parts[0])
+		if i < 0 {
+			return false
+		}
+
+		if len(parts) > 1 {
+			child := node.Content[i]
+			if !yamlPathRemove(child, parts[1:]) {
+				return false
+			}
+			if child.Kind != yaml.MappingNode || len(child.Content) > 0 {
+				return true
+			}
+		}
+
+		node.Content = append(node.Content[:i-1], node.Content[i+1:]...)
+		return true
+
+	case yaml.SequenceNode:
+		index, err := yamlSequenceIndex(parts[0], len(node.Content)-1)
+		if err != nil {
+			return false
+		}
+
+		if len(parts) > 1 {
+			return yamlPathRemove(node.Content[index], parts[1:])
+		}
+
+		node.Content = append(node.Content[:index], node.Content[index+1:]...)
+		return true
+	}
+
+	return false
+}
+
+// yamlMerge deep-merges a node into a target node and returns the merged node: mappings are
+// merged, other values being replaced
+func yamlMerge(target, patch *yaml.Node) *yaml.Node {
+	if target == nil || target.Kind != yaml.MappingNode || patch.Kind != yaml.MappingNode {
+		return yamlReplace(target, patch)
+	}
+
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		key, value := patch.Content[i], patch.Content[i+1]
+		if j := yamlMappingIndex(target, key.Value); j >= 0 {
+			target.Content[j] = yamlMerge(target.Content[j], value)
+		} else {
+			target.Content = append(target.Content, key, value)
+		}
+	}
+
+	return target
+}
+
+// yamlMergePaths returns the paths of the values set by the merge of a node, relative to prefix
+// (keys holding dots or quotes being quoted)
+func yamlMergePaths(patch *yaml.Node, prefix string) []string {
+	if patch.Kind != yaml.MappingNode || len(patch.Content) == 0 {
+		return []string{prefix}
+	}
+
+	var paths []string
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		key := patch.Content[i].Value
+		if key == "" || strings.ContainsAny(key, ".\"'") {
+			key = strconv.Quote(key)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		paths = append(paths, yamlMergePaths(patch.Content[i+1], key)...)
+	}
+	return paths
+}
+
+// yamlMergeProjection returns the part of a target node managed by the merge of a node, which
+// equals the node when it is merged
+func yamlMergeProjection(target, patch *yaml.Node) *yaml.Node {
+	if target.Kind != yaml.MappingNode || patch.Kind != yaml.MappingNode {
+		return target
+	}
+
+	projection := newYAMLMapping()
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		if j := yamlMappingIndex(target, patch.Content[i].Value); j >= 0 {
+			projection.Content = append(projection.Content, target.Content[j-1], yamlMergeProjection(target.Content[j], patch.Content[i+1]))
+		}
+	}
+
+	return projection
+}
+
+// yamlMergeRemove removes the keys set by the merge of a node from a target node, along with the
+// mappings left empty by the removal
+func yamlMergeRemove(target, patch *yaml.Node) {
+	if target.Kind != yaml.MappingNode || patch.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		j := yamlMappingIndex(target, patch.Content[i].Value)
+		if j < 0 {
+			continue
+		}
+
+		child, value := target.Content[j], patch.Content[i+1]
+		if child.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			if yamlMergeRemove(child, value); len(child.Content) > 0 {
+				continue
+			}
+		}
+
+		target.Content = append(target.Content[:j-1], target.Content[j+1:]...)
+	}
+}
+
+// yamlMergeExclude returns a mapping node setting the keys set by patch but not by exclude, if set
+func yamlMergeExclude(patch, exclude *yaml.Node) *yaml.Node {
+	if exclude == nil || patch.Kind != yaml.MappingNode || exclude.Kind != yaml.MappingNode {
+		return patch
+	}
+
+	difference := newYAMLMapping()
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		key, value := patch.Content[i], patch.Content[i+1]
+		j := yamlMappingIndex(exclude, key.Value)
+		if j < 0 {
+			difference.Content = append(difference.Content, key, value)
+			continue
+		}
+
+		if value.Kind == yaml.MappingNode && exclude.Content[j].Kind == yaml.MappingNode {
+			if child := yamlMergeExclude(value, exclude.Content[j]); len(child.Content) > 0 {
+				difference.Content = append(difference.Content, key, child)
+			}
+		}
+	}
+
+	return difference
+}
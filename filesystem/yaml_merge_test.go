@@ -0,0 +1,261 @@
+package filesystem
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const testYAMLContent = `# deployment
+spec:
+  replicas: 1 # scaled by hand
+  template:
+    containers:
+      - name: app
+        image: app:1.0
+`
+
+// testYAMLFormattedContent holds blank lines, comments, flow collections, quoted and block scalars
+// and a second document, all left untouched by edits
+const testYAMLFormattedContent = `# servers
+
+web:
+    hosts: [web1, "web2"]   # flow
+
+    port: 80   # http
+    motd: |
+        welcome
+
+db:
+  - {name: main, port: 5432}
+  - name: 'replica'
+    port: 5433
+---
+other: document
+`
+
+func TestEditYAML(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Path     string
+		Value    string
+		Remove   bool
+		Expected string
+	}{
+		"replaced": {
+			Content:  testYAMLContent,
+			Path:     "spec.replicas",
+			Value:    "3",
+			Expected: "# deployment\nspec:\n  replicas: 3 # scaled by hand\n  template:\n    containers:\n      - name: app\n        image: app:1.0\n",
+		},
+		"sequence element": {
+			Content:  testYAMLContent,
+			Path:     "spec.template.containers.0.image",
+			Value:    "app:1.1",
+			Expected: "# deployment\nspec:\n  replicas: 1 # scaled by hand\n  template:\n    containers:\n      - name: app\n        image: app:1.1\n",
+		},
+		"added": {
+			Content:  testYAMLContent,
+			Path:     "metadata.labels.app",
+			Value:    "app",
+			Expected: testYAMLContent + "metadata:\n  labels:\n    app: app\n",
+		},
+		"dotted key": {
+			Content:  testYAMLContent,
+			Path:     `metadata.labels."app.kubernetes.io/name"`,
+			Value:    "app",
+			Expected: testYAMLContent + "metadata:\n  labels:\n    app.kubernetes.io/name: app\n",
+		},
+		"created": {
+			Content:  "",
+			Path:     "a.b",
+			Value:    "[1, 2]",
+			Expected: "a:\n  b: [1, 2]\n",
+		},
+		"formatting preserved": {
+			Content:  testYAMLFormattedContent,
+			Path:     "web.port",
+			Value:    "8080",
+			Expected: strings.Replace(testYAMLFormattedContent, "port: 80 ", "port: 8080 ", 1),
+		},
+		"flow sequence element": {
+			Content:  testYAMLFormattedContent,
+			Path:     "web.hosts.1",
+			Value:    "web3",
+			Expected: strings.Replace(testYAMLFormattedContent, `[web1, "web2"]`, "[web1, web3]", 1),
+		},
+		"flow mapping value": {
+			Content:  testYAMLFormattedContent,
+			Path:     "db.0.port",
+			Value:    "5434",
+			Expected: strings.Replace(testYAMLFormattedContent, "port: 5432", "port: 5434", 1),
+		},
+		"added with document indentation": {
+			Content:  testYAMLFormattedContent,
+			Path:     "web.tls.enabled",
+			Value:    "true",
+			Expected: strings.Replace(testYAMLFormattedContent, "welcome\n", "welcome\n    tls:\n        enabled: true\n", 1),
+		},
+		"sequence item added": {
+			Content:  testYAMLFormattedContent,
+			Path:     "db.2",
+			Value:    "name: backup\nport: 5435",
+			Expected: strings.Replace(testYAMLFormattedContent, "port: 5433\n", "port: 5433\n  - name: backup\n    port: 5435\n", 1),
+		},
+		"block scalar removed": {
+			Content:  testYAMLFormattedContent,
+			Path:     "web.motd",
+			Remove:   true,
+			Expected: strings.Replace(testYAMLFormattedContent, "    motd: |\n        welcome\n", "", 1),
+		},
+		"sequence item removed": {
+			Content:  testYAMLFormattedContent,
+			Path:     "db.0",
+			Remove:   true,
+			Expected: strings.Replace(testYAMLFormattedContent, "  - {name: main, port: 5432}\n", "", 1),
+		},
+		"crlf": {
+			Content:  "a:\r\n  b: 1\r\n",
+			Path:     "a.c",
+			Value:    "2",
+			Expected: "a:\r\n  b: 1\r\n  c: 2\r\n",
+		},
+		"removed": {
+			Content:  "a:\n  b: 1\nc: 2 # kept\n",
+			Path:     "a.b",
+			Remove:   true,
+			Expected: "c: 2 # kept\n",
+		},
+	}
+
+	for name, tc := range cases {
+		parts, err := parseYAMLPath(tc.Path)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		edited, err := editYAML(tc.Content, func(document *yaml.Node) (*yaml.Node, error) {
+			if tc.Remove {
+				yamlPathRemove(document, parts)
+				return document, nil
+			}
+
+			value, err := decodeYAMLNode(tc.Value)
+			if err != nil {
+				return nil, err
+			}
+			return yamlPathSet(document, parts, value)
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if edited != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, edited, tc.Expected)
+		}
+	}
+}
+
+func TestParseYAMLPath(t *testing.T) {
+	cases := map[string]struct {
+		Path     string
+		Expected []string
+		Error    bool
+	}{
+		"dotted":        {Path: "spec.template.containers.0", Expected: []string{"spec", "template", "containers", "0"}},
+		"double quoted": {Path: `metadata.labels."app.kubernetes.io/name"`, Expected: []string{"metadata", "labels", "app.kubernetes.io/name"}},
+		"single quoted": {Path: `'a.b'.'c"d'`, Expected: []string{"a.b", `c"d`}},
+		"escaped":       {Path: `"a\"b"`, Expected: []string{`a"b`}},
+		"empty key":     {Path: "a..b", Error: true},
+		"trailing dot":  {Path: "a.", Error: true},
+		"unterminated":  {Path: `a."b.c`, Error: true},
+		"unexpected":    {Path: `"a"b`, Error: true},
+	}
+
+	for name, tc := range cases {
+		parts, err := parseYAMLPath(tc.Path)
+		if tc.Error {
+			if err == nil {
+				t.Fatalf("%s: expected error, got parts %q", name, parts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !reflect.DeepEqual(parts, tc.Expected) {
+			t.Fatalf("%s: parts (%q) different from expected parts (%q)", name, parts, tc.Expected)
+		}
+	}
+}
+
+func TestYAMLMerge(t *testing.T) {
+	const merge = "spec:\n  replicas: 3\n  strategy:\n    type: Recreate\n"
+
+	mergeNode, _ := decodeYAMLNode(merge)
+
+	merged, err := editYAML(testYAMLContent, func(document *yaml.Node) (*yaml.Node, error) {
+		return yamlMerge(document, mergeNode), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "# deployment\nspec:\n  replicas: 3 # scaled by hand\n  template:\n    containers:\n      - name: app\n        image: app:1.0\n  strategy:\n    type: Recreate\n"
+	if merged != expected {
+		t.Fatalf("merged content (%q) different from expected content (%q)", merged, expected)
+	}
+
+	document, _ := decodeYAMLNode(merged)
+	if projection, _ := encodeYAMLNode(yamlMergeProjection(document, mergeNode)); !equivalentYAML(projection, merge) {
+		t.Fatalf("projection (%q) different from expected projection (%q)", projection, merge)
+	}
+
+	removed, err := editYAML(merged, func(document *yaml.Node) (*yaml.Node, error) {
+		yamlMergeRemove(document, mergeNode)
+		return document, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "# deployment\nspec:\n  template:\n    containers:\n      - name: app\n        image: app:1.0\n"
+	if removed != expected {
+		t.Fatalf("content (%q) different from expected content (%q)", removed, expected)
+	}
+}
+
+func TestYAMLMergeRemoveAll(t *testing.T) {
+	cases := map[string]struct {
+		Content  string
+		Paths    []string
+		Expected string
+	}{
+		"sequence items": {
+			Content:  "a: [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11]\n",
+			Paths:    []string{"a.9", "a.10"},
+			Expected: "a: [0, 1, 2, 3, 4, 5, 6, 7, 8, 11]\n",
+		},
+		"nested sequence items": {
+			Content:  "a:\n  b: [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11]\nc: 1\n",
+			Paths:    []string{"a.b.10", "c", "a.b.9", "a.b.2"},
+			Expected: "a:\n  b: [0, 1, 3, 4, 5, 6, 7, 8, 11]\n",
+		},
+	}
+
+	for name, tc := range cases {
+		edited, err := editYAML(tc.Content, func(document *yaml.Node) (*yaml.Node, error) {
+			return yamlMergeRemoveAll(document, tc.Paths, nil)
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if edited != tc.Expected {
+			t.Fatalf("%s: content (%q) different from expected content (%q)", name, edited, tc.Expected)
+		}
+	}
+}
@@ -1,11 +1,45 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/d2si-oss/terraform-provider-filesystem/filesystem"
 	"github.com/hashicorp/terraform/plugin"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rollback" {
+		os.Exit(rollback(os.Args[2:]))
+	}
+
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: filesystem.Provider})
 }
+
+// rollback restores the file snapshots of a transactional provider run, given either the run
+// directory or the journal directory (in which case the latest run is rolled back)
+func rollback(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s rollback <journal directory | run directory>\n", os.Args[0])
+		return 2
+	}
+
+	runDir := args[0]
+	if runs, _ := filepath.Glob(filepath.Join(runDir, "*.json")); len(runs) == 0 {
+		var err error
+		if runDir, err = filesystem.LatestRun(runDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if err := filesystem.Rollback(runDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: unable to roll back %s: %s\n", runDir, err)
+		return 1
+	}
+
+	fmt.Printf("Rolled back %s\n", runDir)
+	return 0
+}